@@ -0,0 +1,116 @@
+package dualgrid
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"slices"
+	"time"
+)
+
+var FrameLayoutError = errors.New("Material frames dont have the same layout")
+
+// NewAnimatedMaterial joins materials with the same TileSize, TileCount and VarientMap into
+// one animated Material showing each of them for frameDuration, in order.
+// Frames are placed one after the other in the texture strip.
+//
+// Pixels are kept when every frame has them (see NewMaterialFromTilemapImage), the Texture
+// is then created on the first GPU render.
+func NewAnimatedMaterial(frameDuration time.Duration, frames ...Material) (Material, error) {
+	if len(frames) == 0 {
+		return Material{}, FrameLayoutError
+	}
+	first := frames[0]
+	cpu := true
+	for _, f := range frames {
+		if f.TileSize != first.TileSize || f.TileCount != first.TileCount || f.frameCount() != 1 {
+			return Material{}, FrameLayoutError
+		}
+		for bitmask := range f.VarientMap {
+			if !slices.Equal(f.VarientMap[bitmask], first.VarientMap[bitmask]) {
+				return Material{}, FrameLayoutError
+			}
+		}
+		cpu = cpu && f.Pixels != nil
+	}
+
+	m := first
+	m.Frames = len(frames)
+	m.FrameDuration = frameDuration
+	m.Pixels = nil
+	m.Opaque = nil
+	w := first.TileCount * first.TileSize
+	if cpu {
+		m.joinFrameTextures(nil)
+		m.Pixels = image.NewRGBA(image.Rect(0, 0, m.Frames*w, m.TileSize))
+		for i, f := range frames {
+			draw.Draw(m.Pixels, image.Rect(i*w, 0, (i+1)*w, m.TileSize), f.Pixels, image.Point{}, draw.Src)
+		}
+		m.Opaque = frameOpacity(slotOpacity(m.Pixels.Pix, m.Pixels.Stride, m.TileSize, m.TileCount*m.Frames), m.TileCount)
+		return m, nil
+	}
+
+	m.joinFrameTextures(frames)
+	return m, nil
+}
+
+// NewMaterialFromMaskImageFrames builds the same Material as NewMaterialFromMaskFrames from
+// image.Image sources, on the CPU and without any ebiten call.
+func NewMaterialFromMaskImageFrames(tileSize int, textureImages []image.Image, maskImage image.Image, varientMap VarientMap, frameDuration time.Duration) (Material, error) {
+	frames := make([]Material, len(textureImages))
+	for i, texture := range textureImages {
+		f, err := NewMaterialFromMaskImage(tileSize, texture, maskImage, varientMap)
+		if err != nil {
+			return Material{}, err
+		}
+		frames[i] = f
+	}
+	return NewAnimatedMaterial(frameDuration, frames...)
+}
+
+// frameCount returns the number of frames in the material texture strip.
+func (m *Material) frameCount() int {
+	return max(m.Frames, 1)
+}
+
+// frameOffset returns the first slot of the frame shown at time t.
+func (m *Material) frameOffset(t time.Duration) int {
+	if m.Frames <= 1 || m.FrameDuration <= 0 {
+		return 0
+	}
+	frame := int(t/m.FrameDuration) % m.Frames
+	if frame < 0 {
+		frame += m.Frames
+	}
+	return frame * m.TileCount
+}
+
+// frameOpacity folds the per slot opacity of every frame into one entry per slot,
+// true when the slot is opaque in every frame.
+func frameOpacity(opaque []bool, tileCount int) []bool {
+	folded := opaque[:tileCount]
+	for i := tileCount; i < len(opaque); i++ {
+		folded[i%tileCount] = folded[i%tileCount] && opaque[i]
+	}
+	return folded
+}
+
+// AdvanceClock moves the animation clock forward by dt, call it from Update:
+//
+//	dg.AdvanceClock(time.Second / time.Duration(ebiten.TPS()))
+//
+// The canvas is marked dirty when an animated material changes frame.
+func (dg *DualGrid) AdvanceClock(dt time.Duration) {
+	before := dg.Clock
+	dg.Clock += dt
+	for i := range dg.Materials {
+		if dg.Materials[i].frameOffset(before) != dg.Materials[i].frameOffset(dg.Clock) {
+			dg.dirty = true
+		}
+	}
+	for i := range dg.Transitions {
+		if dg.Transitions[i].Material.frameOffset(before) != dg.Transitions[i].Material.frameOffset(dg.Clock) {
+			dg.dirty = true
+		}
+	}
+}
@@ -0,0 +1,52 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// joinFrameTextures sets m.Texture to the Texture of every frame one after the other, or to nil
+// for frames without a texture, m.Texture then being uploaded from Pixels.
+func (m *Material) joinFrameTextures(frames []Material) {
+	m.Texture = nil
+	if len(frames) == 0 {
+		return
+	}
+	w := m.TileCount * m.TileSize
+	m.Texture = ebiten.NewImage(len(frames)*w, m.TileSize)
+	var opts ebiten.DrawImageOptions
+	for i, f := range frames {
+		opts.GeoM.Reset()
+		opts.GeoM.Translate(float64(i*w), 0)
+		m.Texture.DrawImage(f.texture(), &opts)
+	}
+}
+
+// NewMaterialFromMaskFrames stamps every texture through the same mask, like NewMaterialFromMask,
+// and returns them as one animated Material (see NewAnimatedMaterial).
+func NewMaterialFromMaskFrames(tileSize int, textureImages []*ebiten.Image, maskImage *ebiten.Image, varientMap VarientMap, frameDuration time.Duration) (Material, error) {
+	frames := make([]Material, len(textureImages))
+	for i, texture := range textureImages {
+		f, err := NewMaterialFromMask(tileSize, texture, maskImage, varientMap)
+		if err != nil {
+			return Material{}, err
+		}
+		frames[i] = f
+	}
+	m, err := NewAnimatedMaterial(frameDuration, frames...)
+	for _, f := range frames {
+		f.Texture.Deallocate()
+	}
+	return m, err
+}
+
+// DrawToAt is DrawTo showing animated materials at time t instead of Clock.
+func (dg *DualGrid) DrawToAt(img *ebiten.Image, left, top int, t time.Duration) {
+	clock := dg.Clock
+	dg.Clock = t
+	dg.DrawTo(img, left, top)
+	dg.Clock = clock
+}
@@ -0,0 +1,57 @@
+package dualgrid
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+// An animated material renders exactly like each of its frames as a still material.
+func TestAnimatedMaterial(t *testing.T) {
+	types := toRGBA(loadAsset(t, "materialTypes"))
+	var textures []image.Image
+	for i := range 3 {
+		textures = append(textures, types.SubImage(image.Rect(i*testTileSize, 0, (i+1)*testTileSize, testTileSize)))
+	}
+	mask := loadAsset(t, "grassMask")
+	varients := VarientMap{3: {17}, 5: {16}}
+	animated, err := NewMaterialFromMaskImageFrames(testTileSize, textures, mask, varients, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(m Material, clock time.Duration) *image.RGBA {
+		dg := simpleGrid(t)
+		dg.Materials[2] = m
+		dg.Clock = clock
+		img := image.NewRGBA(image.Rect(0, 0, (dg.WorldGrid.Width+1)*dg.TileSize, (dg.WorldGrid.Height+1)*dg.TileSize))
+		dg.DrawToRGBA(img, 0, 0)
+		return img
+	}
+	for i, texture := range textures {
+		still, err := NewMaterialFromMaskImage(testTileSize, texture, mask, varients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock := time.Duration(i)*100*time.Millisecond + 50*time.Millisecond
+		if string(render(animated, clock).Pix) != string(render(still, 0).Pix) {
+			t.Errorf("frame %d differs from its still material", i)
+		}
+	}
+
+	dg := simpleGrid(t)
+	dg.Materials[2] = animated
+	dg.dirty = false
+	dg.AdvanceClock(60 * time.Millisecond)
+	if dg.dirty {
+		t.Error("AdvanceClock marked the canvas dirty without a frame change")
+	}
+	dg.AdvanceClock(60 * time.Millisecond)
+	if !dg.dirty {
+		t.Error("AdvanceClock did not mark the canvas dirty on a frame change")
+	}
+
+	if _, err := NewAnimatedMaterial(time.Second, animated); err != FrameLayoutError {
+		t.Errorf("NewAnimatedMaterial of an animated material: err = %v, want FrameLayoutError", err)
+	}
+}
@@ -0,0 +1,89 @@
+package dualgrid
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+)
+
+var AutotileDimensionError = errors.New("Autotile Image isnt the right dimension")
+
+// autotileMinitiles returns the minitile (half tile) of an RPG Maker A2 autotile block shown in
+// each quadrant (TL, TR, BL, BR) of the dual-grid tile bitmask, in minitile coords, and false
+// for quadrants left empty.
+//
+// The block is 2x3 tiles, 4x6 minitiles: the top-left tile is the isolated preview, the
+// top-right tile holds the inner corners and the 2x2 tiles below show a patch of terrain,
+// borders around and interior in the middle. A dual-grid quadrant is the quadrant of its corner
+// cell facing the tile center, picked from the neighbours of that cell on the other corners.
+func autotileMinitiles(bitmask int) (minitiles [4]image.Point, ok [4]bool) {
+	has := func(x, y int) bool { return bitmask>>(3-(y*2+x))&1 == 1 }
+	for q := range 4 {
+		qx, qy := q%2, q/2
+		if !has(qx, qy) {
+			continue
+		}
+		// Facing right for a left quadrant, with the neighbour in that direction
+		right, bottom := qx == 0, qy == 0
+		h, v, d := has(1-qx, qy), has(qx, 1-qy), has(1-qx, 1-qy)
+
+		var p image.Point
+		switch {
+		case h && v && !d: // inner corner
+			p = image.Pt(2, 0)
+			if right {
+				p.X++
+			}
+			if bottom {
+				p.Y++
+			}
+		default: // corner, edge or interior of the terrain patch
+			p = image.Pt(0, 2)
+			if h {
+				p.X += 2
+			}
+			if v {
+				p.Y += 2
+			}
+			if right {
+				p.X = 3 - p.X
+			}
+			if bottom {
+				p.Y = 7 - p.Y
+			}
+		}
+		minitiles[q], ok[q] = p, true
+	}
+	return minitiles, ok
+}
+
+// checkAutotile validates the size of an A2 autotile block.
+func checkAutotile(tileSize int, b image.Rectangle) error {
+	if tileSize <= 0 || tileSize%2 != 0 || b.Dx() != 2*tileSize || b.Dy() != 3*tileSize {
+		return AutotileDimensionError
+	}
+	return nil
+}
+
+// NewMaterialFromAutotileImage builds the same Material as NewMaterialFromAutotile from an
+// image.Image, on the CPU and without any ebiten call. See NewMaterialFromTilemapImage.
+func NewMaterialFromAutotileImage(tileSize int, autotileImage image.Image) (Material, error) {
+	if err := checkAutotile(tileSize, autotileImage.Bounds()); err != nil {
+		return Material{}, err
+	}
+
+	autotile := toRGBA(autotileImage)
+	half := tileSize / 2
+	strip := image.NewRGBA(image.Rect(0, 0, 16*tileSize, tileSize))
+	for bitmask := range 16 {
+		minitiles, ok := autotileMinitiles(bitmask)
+		for q, p := range minitiles {
+			if !ok[q] {
+				continue
+			}
+			dst := image.Rect(0, 0, half, half).Add(image.Pt(bitmask*tileSize+q%2*half, q/2*half))
+			draw.Draw(strip, dst, autotile, p.Mul(half), draw.Src)
+		}
+	}
+	return NewMaterialFromTilemapImageLayout(tileSize, strip, LayoutBitmaskStrip, VarientMap{})
+}
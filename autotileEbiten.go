@@ -0,0 +1,36 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// NewMaterialFromAutotile composes the 16 dual-grid tiles from one RPG Maker A2 autotile block
+// (2x3 tiles of tileSize, even, crop it from the sheet with SubImage) and builds a Material.
+func NewMaterialFromAutotile(tileSize int, autotileImage *ebiten.Image) (Material, error) {
+	b := autotileImage.Bounds()
+	if err := checkAutotile(tileSize, b); err != nil {
+		return Material{}, err
+	}
+
+	half := tileSize / 2
+	strip := ebiten.NewImage(16*tileSize, tileSize)
+	defer strip.Deallocate()
+	var opts ebiten.DrawImageOptions
+	for bitmask := range 16 {
+		minitiles, ok := autotileMinitiles(bitmask)
+		for q, p := range minitiles {
+			if !ok[q] {
+				continue
+			}
+			src := image.Rect(p.X*half, p.Y*half, (p.X+1)*half, (p.Y+1)*half).Add(b.Min)
+			opts.GeoM.Reset()
+			opts.GeoM.Translate(float64(bitmask*tileSize+q%2*half), float64(q/2*half))
+			strip.DrawImage(autotileImage.SubImage(src).(*ebiten.Image), &opts)
+		}
+	}
+	return NewMaterialFromTilemapLayout(tileSize, strip, LayoutBitmaskStrip, VarientMap{})
+}
@@ -0,0 +1,53 @@
+package dualgrid
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestAutotile(t *testing.T) {
+	const ts = testTileSize
+	const half = ts / 2
+	// every minitile of the 2x3 block in its own color
+	minitileColor := func(x, y int) color.RGBA { return color.RGBA{uint8(x * 60), uint8(y * 40), 0, 255} }
+	autotile := image.NewRGBA(image.Rect(0, 0, 2*ts, 3*ts))
+	for x := range 4 {
+		for y := range 6 {
+			draw.Draw(autotile, image.Rect(x*half, y*half, (x+1)*half, (y+1)*half), image.NewUniform(minitileColor(x, y)), image.Point{}, draw.Src)
+		}
+	}
+	m, err := NewMaterialFromAutotileImage(ts, autotile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Minitile expected in the TL, TR, BL and BR quadrants, (-1, -1) for empty
+	none := image.Pt(-1, -1)
+	for bitmask, want := range map[int][4]image.Point{
+		0b0000: {none, none, none, none},
+		0b1111: {{1, 3}, {2, 3}, {1, 4}, {2, 4}}, // interior
+		0b1000: {{3, 5}, none, none, none},       // outer corner of the top left cell
+		0b1100: {{1, 5}, {2, 5}, none, none},     // bottom border of the top cells
+		0b1110: {{3, 1}, {2, 5}, {3, 4}, none},   // inner corner facing the missing corner
+		0b1001: {{3, 5}, none, none, {0, 2}},     // diagonal, two outer corners
+	} {
+		for q, p := range want {
+			x, y := bitmask*ts+q%2*half+half/2, q/2*half+half/2
+			got := m.Pixels.RGBAAt(x, y)
+			wantColor := color.RGBA{}
+			if p != none {
+				wantColor = minitileColor(p.X, p.Y)
+			}
+			if got != wantColor {
+				t.Errorf("bitmask %04b quadrant %d = %v, want minitile %v", bitmask, q, got, p)
+			}
+		}
+	}
+
+	if _, err := NewMaterialFromAutotileImage(ts, autotile.SubImage(image.Rect(0, 0, 2*ts, 2*ts))); !errors.Is(err, AutotileDimensionError) {
+		t.Errorf("2x2 block: err = %v, want AutotileDimensionError", err)
+	}
+}
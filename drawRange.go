@@ -0,0 +1,23 @@
+package dualgrid
+
+// drawRange limits rendering to the materials at draw positions [from, to), set while
+// DrawRange runs. The zero value draws everything.
+type drawRange struct {
+	from, to int
+	set      bool
+}
+
+// DrawOrder returns the materials bottom to top (see Priority). The index of a material in it
+// is its draw position, as used by DrawRange and DrawToWithHook.
+func (dg *DualGrid) DrawOrder() []TileType {
+	_, order := dg.priorities()
+	return order
+}
+
+// drawBounds returns the draw positions to render, out of numMats materials.
+func (dg *DualGrid) drawBounds(numMats int) (from, to int) {
+	if !dg.drawRange.set {
+		return 0, numMats
+	}
+	return max(dg.drawRange.from, 0), min(dg.drawRange.to, numMats)
+}
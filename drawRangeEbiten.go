@@ -0,0 +1,38 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// DrawRange draws the materials at draw positions [from, to) over img, without clearing it,
+// from the given top-left world pixel coord. Tiles are still resolved with every material,
+// so two ranges drawn one after the other give the same image as DrawTo:
+//
+//	dg.DrawRange(screen, left, top, 0, 1) // floor
+//	// draw characters
+//	dg.DrawRange(screen, left, top, 1, len(dg.Materials)) // walls
+func (dg *DualGrid) DrawRange(img *ebiten.Image, left, top, from, to int) {
+	dg.drawRange = drawRange{from: from, to: to, set: true}
+	defer func() { dg.drawRange = drawRange{} }()
+	dg.render(img, left, top)
+}
+
+// DrawToWithHook clears img and renders the DualGrid into it like DrawTo, calling hook after
+// each material, bottom to top, with its draw position. Draw anything that goes between
+// material layers from hook.
+//
+// The VertexRenderer issues at least one draw call per material instead of one in total,
+// other renderers run once per material.
+func (dg *DualGrid) DrawToWithHook(img *ebiten.Image, left, top int, hook func(img *ebiten.Image, position int, material TileType)) {
+	img.Clear()
+	if dg.Renderer == nil {
+		dg.hook = hook
+		defer func() { dg.hook = nil }()
+		dg.renderTo(img, left, top)
+		return
+	}
+	for position, m := range dg.DrawOrder() {
+		dg.DrawRange(img, left, top, position, position+1)
+		hook(img, position, m)
+	}
+}
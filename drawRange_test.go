@@ -0,0 +1,29 @@
+package dualgrid
+
+import (
+	"image"
+	"testing"
+)
+
+// Drawing the materials in two ranges gives the same image as drawing them at once.
+func TestDrawRange(t *testing.T) {
+	dg := dungeonGrid(t)
+	r := image.Rect(0, 0, (dg.WorldGrid.Width+1)*dg.TileSize, (dg.WorldGrid.Height+1)*dg.TileSize)
+	want := image.NewRGBA(r)
+	dg.DrawToRGBA(want, 0, 0)
+
+	got := image.NewRGBA(r)
+	var sr SoftwareRenderer
+	for _, dr := range []drawRange{{from: 0, to: 1, set: true}, {from: 1, to: 3, set: true}} {
+		dg.drawRange = dr
+		sr.RenderRGBA(&dg, got, 0, 0)
+	}
+	if string(got.Pix) != string(want.Pix) {
+		t.Error("two draw ranges differ from a full render")
+	}
+
+	// The last range left the floor out
+	if q := dg.Stats().Quads; q[0] != 0 || q[1] == 0 {
+		t.Errorf("Quads = %v, want only walls", q)
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"image"
-
-	"github.com/hajimehoshi/ebiten/v2"
+	"image/draw"
+	"math"
+	"time"
 )
 
 // TileType identifies a material by its index in DualGrid.Materials.
@@ -18,12 +19,47 @@ type DualGrid struct {
 	TileSize        int
 	DefaultMaterial TileType
 	WorldGrid       Grid
-	Materials       []Material
-	canvas          *ebiten.Image
-	dirty           bool
-	// Cached render buffers, reused across frames
-	vertices [][]ebiten.Vertex
-	indices  [][]uint16
+	// Materials are packed in an atlas on the first GPU render. Call MarkDirty after replacing
+	// one in place or redrawing its Texture (setting its Opaque to nil if the alpha changed),
+	// AddMaterial does it for appended ones.
+	Materials []Material
+	// Transitions replace the edge tiles of a material over another specific one, see AddTransition
+	Transitions []Transition
+	// Priority is an explicit bottom to top draw order of materials, overriding Material.Z.
+	// Materials missing from it are drawn below the listed ones. nil orders by Z then index.
+	Priority []TileType
+	// Connects reports whether a corner of material corner counts as material m when building
+	// the edges of m, so m covers it instead of showing an edge. Corners drawn at or above m
+	// always count. nil connects materials sharing a non zero Material.Terrain.
+	// With Workers above 1 it is called from several goroutines and must be safe for concurrent use.
+	Connects func(m, corner TileType) bool
+	// VarientStrategy picks the variant of each tile, the default hashes every tile
+	// independently. Seed changes the picks of every strategy, VarientClusterSize is the
+	// patch size of VarientClustered in tiles (0 for 4).
+	VarientStrategy    VarientStrategy
+	VarientClusterSize int
+	Seed               uint32
+	// PickVarient returns the index of the VarientMap[bitmask] entry of material shown on the
+	// tile, out of count, overriding VarientStrategy. It must be deterministic, and safe for
+	// concurrent use with Workers above 1. The ShaderRenderer ignores it.
+	PickVarient func(tileX, tileY int, material TileType, bitmask, count int) int
+	// Workers is the number of goroutines building vertices in renderTo, each taking a
+	// stripe of columns. 0 or 1 builds them serially; the output is the same either way.
+	// Above 1, Connects and PickVarient are called concurrently.
+	Workers int
+	// Tint colors the tiles around each cell, interpolated across tiles. nil draws untinted
+	Tint *TintGrid
+	// Options are the draw time filter, blend, color scale and address mode
+	Options RenderOptions
+	// Clock is the time animated materials are shown at, see AdvanceClock
+	Clock time.Duration
+	// Renderer and GPU resources, empty in headless builds
+	DualGridEbiten
+	dirty bool
+	stats RenderStats
+	// Set while DrawRange and DrawToWithHook run
+	drawRange drawRange
+	scales    []Tint // per material color scale of the current render
 }
 
 func NewDualGrid(width, height, tileSize int, defaultMaterial TileType) DualGrid {
@@ -32,13 +68,13 @@ func NewDualGrid(width, height, tileSize int, defaultMaterial TileType) DualGrid
 		DefaultMaterial: defaultMaterial,
 		TileSize:        tileSize,
 		WorldGrid:       NewGridWithValue(width, height, defaultMaterial),
-		canvas:          ebiten.NewImage((width+1)*tileSize, (height+1)*tileSize),
 		dirty:           true,
 	}
 }
 
 // SetCell updates a single cell.
 func (dg *DualGrid) SetCell(x, y int, t TileType) {
+	dg.WorldGrid.markChanged(x, y, 1, 1)
 	dg.WorldGrid.Cells[x*dg.WorldGrid.Height+y] = t
 }
 
@@ -48,43 +84,31 @@ func (dg *DualGrid) GetCell(x, y int) TileType {
 }
 
 // MarkDirty schedules a full canvas redraw on the next Canvas() call.
-// Call this after bulk modifications to WorldGrid.
+// Call this after bulk modifications to WorldGrid, or after writing WorldGrid.Cells or
+// Tint.Tints directly so the ShaderRenderer uploads them again, or after changing Materials
+// or their textures so the atlas is rebuilt.
 func (dg *DualGrid) MarkDirty() {
 	dg.dirty = true
+	dg.dropAtlas()
+	dg.WorldGrid.markChanged(0, 0, dg.WorldGrid.Width, dg.WorldGrid.Height)
+	if dg.Tint != nil {
+		dg.Tint.changed = image.Rect(0, 0, dg.Tint.Width, dg.Tint.Height)
+	}
 }
 
-// Canvas returns the cached full-grid rendered image, rebuilding it if dirty.
-func (dg *DualGrid) Canvas() *ebiten.Image {
-	w, h := dg.WorldGrid.Width, dg.WorldGrid.Height
-	fullW := (w + 1) * dg.TileSize
-	fullH := (h + 1) * dg.TileSize
-	if dg.canvas == nil || dg.canvas.Bounds().Dx() != fullW || dg.canvas.Bounds().Dy() != fullH {
-		if dg.canvas != nil {
-			dg.canvas.Deallocate()
-		}
-		dg.canvas = ebiten.NewImage(fullW, fullH)
-		dg.dirty = true
-	}
-	if dg.dirty {
-		dg.dirty = false
-		dg.DrawTo(dg.canvas, 0, 0)
-	}
-	return dg.canvas
+// TileOffset returns the world pixel position of the top-left corner of the canvas returned by
+// ViewCanvasF(viewW, viewH, worldLeft, worldTop), or by ViewCanvas for integer coords.
+//
+// Dual-grid tiles sit half a tile up and left of the cells they show, the offset
+// accounts for it: cell (0, 0) covers the world pixels [0, TileSize).
+func (dg *DualGrid) TileOffset(worldLeft, worldTop float64) (x, y float64) {
+	return tileOffset(worldLeft, worldTop, dg.TileSize)
 }
 
-// ViewCanvas renders only the visible world region (viewW×viewH world pixels starting at
-// worldLeft,worldTop) into the internal canvas, resizing it if needed.
-// The returned image should be drawn to screen using TileOffset() to correct alignment.
-// Unlike Canvas(), this always redraws — use it when the viewport moves every frame.
-func (dg *DualGrid) ViewCanvas(viewW, viewH, worldLeft, worldTop int) *ebiten.Image {
-	if dg.canvas == nil || dg.canvas.Bounds().Dx() != viewW || dg.canvas.Bounds().Dy() != viewH {
-		if dg.canvas != nil {
-			dg.canvas.Deallocate()
-		}
-		dg.canvas = ebiten.NewImage(viewW, viewH)
-	}
-	dg.DrawTo(dg.canvas, worldLeft, worldTop)
-	return dg.canvas
+// tileOffset returns the world pixel position of a view canvas rendered from worldLeft,worldTop.
+func tileOffset(worldLeft, worldTop float64, tileSize int) (x, y float64) {
+	half := float64(tileSize) / 2
+	return math.Floor(worldLeft) - half, math.Floor(worldTop) - half
 }
 
 // Check if a X, Y coord is inside the bounds of the grid
@@ -134,11 +158,8 @@ func (dg *DualGrid) Unmarshal(data []byte, forceResize bool) error {
 		if !forceResize {
 			return fmt.Errorf("grid size mismatch: file has %dx%d, current is %dx%d", width, height, dg.WorldGrid.Width, dg.WorldGrid.Height)
 		}
+		// The canvas is resized on the next Canvas() call
 		dg.WorldGrid = NewGridWithValue(width, height, defaultMaterial)
-		if dg.canvas != nil {
-			dg.canvas.Deallocate()
-		}
-		dg.canvas = ebiten.NewImage((width+1)*dg.TileSize, (height+1)*dg.TileSize)
 	}
 	if len(data) < 14+width*height {
 		return errors.New("data truncated")
@@ -147,169 +168,57 @@ func (dg *DualGrid) Unmarshal(data []byte, forceResize bool) error {
 	for i, v := range data[14 : 14+width*height] {
 		dg.WorldGrid.Cells[i] = TileType(v)
 	}
-	dg.dirty = true
+	dg.MarkDirty()
 	return nil
 }
 
-// AddMaterial appends a Material to the DualGrid.
+// AddMaterial appends a Material to the DualGrid. The shared atlas is rebuilt on the next GPU render.
 func (dg *DualGrid) AddMaterial(m Material) {
 	dg.Materials = append(dg.Materials, m)
+	dg.dropAtlas()
 	dg.dirty = true
 }
 
-// DrawTo clears img and renders the DualGrid into it from the given top-left world pixel coord.
-func (dg *DualGrid) DrawTo(img *ebiten.Image, left, top int) {
-	img.Clear()
-	dg.renderTo(img, left, top)
+// DrawToRGBA clears dst and renders the DualGrid into it on the CPU from the given top-left
+// world pixel coord. It never calls ebiten, see SoftwareRenderer.
+func (dg *DualGrid) DrawToRGBA(dst *image.RGBA, left, top int) {
+	draw.Draw(dst, dst.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	var sr SoftwareRenderer
+	sr.RenderRGBA(dg, dst, left, top)
 }
 
-// RedrawCanvasRegion clears and redraws the tile region at (tileX, tileY) with
-// size (tileW x tileH) on the DualGrid's internal canvas.
-// Automatically expands by one tile on the right/bottom for dual-grid corner overlap.
-func (dg *DualGrid) RedrawCanvasRegion(tileX, tileY, tileW, tileH int) {
-	left := tileX * dg.TileSize
-	top := tileY * dg.TileSize
-	right := (tileX + tileW + 1) * dg.TileSize
-	bottom := (tileY + tileH + 1) * dg.TileSize
-
-	b := dg.canvas.Bounds()
-	left = max(left, b.Min.X)
-	top = max(top, b.Min.Y)
-	right = min(right, b.Max.X)
-	bottom = min(bottom, b.Max.Y)
-	if left >= right || top >= bottom {
-		return
-	}
-	sub := dg.canvas.SubImage(image.Rect(left, top, right, bottom)).(*ebiten.Image)
-	dg.renderTo(sub, left, top)
+// renderView holds the renderTo parameters shared by every stripe.
+type renderView struct {
+	widthInTile, heightInTile int
+	tileStartX, tileStartY    int
+	offsetX, offsetY          float32
+	originX, originY          float32
+	scales                    []Tint // per material color scale, see materialScales
 }
 
-func (dg *DualGrid) renderTo(img *ebiten.Image, left, top int) {
-	bounds := img.Bounds()
-	widthInTile := bounds.Dx() / dg.TileSize
-	heightInTile := bounds.Dy() / dg.TileSize
-	tileStartX := left / dg.TileSize
-	tileStartY := top / dg.TileSize
-	offsetX := float32(left % dg.TileSize)
-	offsetY := float32(top % dg.TileSize)
-	originX := float32(bounds.Min.X)
-	originY := float32(bounds.Min.Y)
-	ts := float32(dg.TileSize)
-	gridW := dg.WorldGrid.Width
-	gridH := dg.WorldGrid.Height
-	cells := dg.WorldGrid.Cells
-
-	var tileX, tileY int
-	var tl, tr, bl, br TileType
-	var matType TileType
-	var matTypeMask [256]bool // TileType is uint8 so max 256 values, no heap alloc per call
-	var bitmask int
-
-	// Reuse cached vertex/index buffers
-	numMats := len(dg.Materials)
-	if len(dg.vertices) < numMats {
-		dg.vertices = make([][]ebiten.Vertex, numMats)
-		dg.indices = make([][]uint16, numMats)
-	}
-	for i := range numMats {
-		dg.vertices[i] = dg.vertices[i][:0]
-		dg.indices[i] = dg.indices[i][:0]
-	}
-
-	for x := range widthInTile {
-		tileX = tileStartX + x
-		if tileX < 0 || tileX >= gridW+1 {
-			continue
-		}
-		for y := range heightInTile {
-			tileY = tileStartY + y
-			if tileY < 0 || tileY >= gridH+1 {
-				continue
-			}
-
-			tl = dg.DefaultMaterial
-			tr = dg.DefaultMaterial
-			bl = dg.DefaultMaterial
-			br = dg.DefaultMaterial
-
-			// If inbound set corners to grid value
-			if tileX >= 1 && tileY >= 1 {
-				tl = cells[(tileX-1)*gridH+(tileY-1)]
-			}
-			if tileX < gridW && tileY >= 1 {
-				tr = cells[tileX*gridH+(tileY-1)]
-			}
-			if tileX >= 1 && tileY < gridH {
-				bl = cells[(tileX-1)*gridH+tileY]
-			}
-			if tileX < gridW && tileY < gridH {
-				br = cells[tileX*gridH+tileY]
-			}
-
-			matTypeMask[tl] = true
-			matTypeMask[tr] = true
-			matTypeMask[bl] = true
-			matTypeMask[br] = true
-
-			dstX := float32(x)*ts - offsetX + originX
-			dstY := float32(y)*ts - offsetY + originY
-
-			// Draw up to 4 layers per tile for "layering"
-			for i := range len(dg.Materials) {
-				if !matTypeMask[i] {
-					continue
-				}
-				matType = TileType(i)
-				bitmask = 0b0000
-				if tl == matType || tl > matType {
-					bitmask |= 1 << 3
-				}
-				if tr == matType || tr > matType {
-					bitmask |= 1 << 2
-				}
-				if bl == matType || bl > matType {
-					bitmask |= 1 << 1
-				}
-				if br == matType || br > matType {
-					bitmask |= 1 << 0
-				}
-
-				// pick a varient using a world-space coords deterministic hash
-				if v := dg.Materials[matType].VarientMap[bitmask]; len(v) > 0 {
-					tileHash := uint32(tileX)*7919 + uint32(tileY)*6151
-					bitmask = v[tileHash%uint32(len(v))]
-				}
-
-				srcX := float32(bitmask) * ts
-				base := uint16(len(dg.vertices[i]))
-
-				// TL, TR, BL, BR
-				dg.vertices[i] = append(dg.vertices[i],
-					ebiten.Vertex{DstX: dstX, DstY: dstY, SrcX: srcX, SrcY: 0, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
-					ebiten.Vertex{DstX: dstX + ts, DstY: dstY, SrcX: srcX + ts, SrcY: 0, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
-					ebiten.Vertex{DstX: dstX, DstY: dstY + ts, SrcX: srcX, SrcY: ts, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
-					ebiten.Vertex{DstX: dstX + ts, DstY: dstY + ts, SrcX: srcX + ts, SrcY: ts, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
-				)
-				dg.indices[i] = append(dg.indices[i],
-					base, base+1, base+2,
-					base+1, base+3, base+2,
-				)
-			}
-
-			// Reset only the entries that were changed
-			matTypeMask[tl] = false
-			matTypeMask[tr] = false
-			matTypeMask[bl] = false
-			matTypeMask[br] = false
-		}
+// newRenderView maps the tiles starting at world pixel (left, top) onto bounds, partly visible
+// tiles on the edges included.
+func (dg *DualGrid) newRenderView(bounds image.Rectangle, left, top int) renderView {
+	ts := dg.TileSize
+	tileStartX, tileStartY := floorDiv(left, ts), floorDiv(top, ts)
+	offsetX, offsetY := left-tileStartX*ts, top-tileStartY*ts
+	return renderView{
+		widthInTile:  (offsetX + bounds.Dx() + ts - 1) / ts,
+		heightInTile: (offsetY + bounds.Dy() + ts - 1) / ts,
+		tileStartX:   tileStartX,
+		tileStartY:   tileStartY,
+		offsetX:      float32(offsetX),
+		offsetY:      float32(offsetY),
+		originX:      float32(bounds.Min.X),
+		originY:      float32(bounds.Min.Y),
 	}
+}
 
-	// One draw call per material
-	var drawOpts ebiten.DrawTrianglesOptions
-	for i, mat := range dg.Materials {
-		if len(dg.vertices[i]) == 0 {
-			continue
-		}
-		img.DrawTriangles(dg.vertices[i], dg.indices[i], mat.Texture, &drawOpts)
+// floorDiv returns a / b rounded toward negative infinity, b > 0.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b < 0 {
+		q--
 	}
+	return q
 }
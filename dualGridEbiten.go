@@ -0,0 +1,405 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// DualGridEbiten is the ebiten side of a DualGrid: the Renderer and the canvas, atlas and
+// vertex buffers drawn with. Headless builds (the dualgrid_headless build tag) leave it empty
+// and the DualGrid without any ebiten dependency.
+type DualGridEbiten struct {
+	// Renderer used by DrawTo, Canvas and ViewCanvas, nil uses the VertexRenderer
+	Renderer Renderer
+	canvas   *ebiten.Image
+	// Every material strip packed in one texture, one row per material then per transition
+	atlas *ebiten.Image
+	// World textures of the strips, bound apart from the atlas, and 1 + the index in worlds
+	// of the world texture of each atlas row, 0 for none (see buildAtlas)
+	worlds     []*ebiten.Image
+	worldIndex []int
+	// Cached render buffers, reused across frames
+	stripes   [][][]ebiten.Vertex // per stripe and material, concatenated into batch in priority order
+	batch     []ebiten.Vertex
+	batchEnds []int    // end of each material in batch, in draw order
+	indices   []uint16 // shared quad index pattern
+	// Set while DrawToWithHook runs
+	hook func(img *ebiten.Image, position int, material TileType)
+}
+
+// maxBatchVertices is the most vertices a single DrawTriangles call can address with uint16 indices.
+const maxBatchVertices = 1 << 16
+
+// Canvas returns the cached full-grid rendered image, rebuilding it if dirty.
+func (dg *DualGrid) Canvas() *ebiten.Image {
+	w, h := dg.WorldGrid.Width, dg.WorldGrid.Height
+	fullW := (w + 1) * dg.TileSize
+	fullH := (h + 1) * dg.TileSize
+	if dg.canvas == nil || dg.canvas.Bounds().Dx() != fullW || dg.canvas.Bounds().Dy() != fullH {
+		if dg.canvas != nil {
+			dg.canvas.Deallocate()
+		}
+		dg.canvas = ebiten.NewImage(fullW, fullH)
+		dg.dirty = true
+	}
+	if dg.dirty {
+		dg.dirty = false
+		dg.DrawTo(dg.canvas, 0, 0)
+	}
+	return dg.canvas
+}
+
+// ViewCanvas renders only the visible world region (viewW×viewH world pixels starting at
+// worldLeft,worldTop) into the internal canvas, resizing it if needed.
+// The returned image should be drawn to screen at TileOffset to correct alignment, see ViewCanvasF
+// for fractional coords.
+// Unlike Canvas(), this always redraws — use it when the viewport moves every frame.
+func (dg *DualGrid) ViewCanvas(viewW, viewH, worldLeft, worldTop int) *ebiten.Image {
+	if dg.canvas == nil || dg.canvas.Bounds().Dx() != viewW || dg.canvas.Bounds().Dy() != viewH {
+		if dg.canvas != nil {
+			dg.canvas.Deallocate()
+		}
+		dg.canvas = ebiten.NewImage(viewW, viewH)
+	}
+	dg.DrawTo(dg.canvas, worldLeft, worldTop)
+	return dg.canvas
+}
+
+// ViewCanvasF is ViewCanvas for a viewport at fractional world pixels, for smooth scrolling
+// and non integer zoom. The canvas covers the viewW×viewH world region starting at
+// worldLeft,worldTop, its size only depends on viewW and viewH.
+// Draw it at TileOffset(worldLeft, worldTop) in world space, then apply the camera transform.
+func (dg *DualGrid) ViewCanvasF(viewW, viewH, worldLeft, worldTop float64) *ebiten.Image {
+	w, h := viewCanvasSize(viewW, viewH, dg.TileSize)
+	return dg.ViewCanvas(w, h, int(math.Floor(worldLeft)), int(math.Floor(worldTop)))
+}
+
+// viewCanvasSize returns the size of a canvas covering a viewW×viewH world region at any
+// fractional position, with the half tile shift of the dual grid.
+func viewCanvasSize(viewW, viewH float64, tileSize int) (w, h int) {
+	half := float64(tileSize) / 2
+	return int(math.Ceil(viewW+half)) + 1, int(math.Ceil(viewH+half)) + 1
+}
+
+// buildAtlas packs every material texture strip into one shared atlas, row i holding
+// material i, so the whole map renders with a single texture.
+// Transition strips follow, row len(Materials)+i holding transition i.
+//
+// World textures can be as large as the GPU allows, they are not packed: each is listed once
+// in dg.worlds and bound as its own source image when drawing.
+//
+// The opacity of materials built from ebiten images is read back here, once (see Material.Opaque).
+func (dg *DualGrid) buildAtlas() {
+	if dg.atlas != nil {
+		dg.atlas.Deallocate()
+		dg.atlas = nil
+	}
+	mats := make([]*Material, 0, dg.atlasRows())
+	for i := range dg.Materials {
+		mats = append(mats, &dg.Materials[i])
+	}
+	for i := range dg.Transitions {
+		mats = append(mats, &dg.Transitions[i].Material)
+	}
+	for _, m := range mats {
+		m.readOpacity()
+	}
+
+	var w int
+	dg.worlds = dg.worlds[:0]
+	dg.worldIndex = slices.Grow(dg.worldIndex[:0], len(mats))[:len(mats)]
+	for row, m := range mats {
+		w = max(w, m.TileCount*m.frameCount()*dg.TileSize)
+		dg.worldIndex[row] = 0
+		if world := m.worldTexture(); world != nil {
+			k := slices.Index(dg.worlds, world)
+			if k < 0 {
+				k = len(dg.worlds)
+				dg.worlds = append(dg.worlds, world)
+			}
+			dg.worldIndex[row] = k + 1
+		}
+	}
+	if w == 0 {
+		return
+	}
+	dg.atlas = ebiten.NewImage(w, len(mats)*dg.TileSize)
+	var opts ebiten.DrawImageOptions
+	for row, m := range mats {
+		if tex := m.texture(); tex != nil {
+			opts.GeoM.Reset()
+			opts.GeoM.Translate(0, float64(row*dg.TileSize))
+			dg.atlas.DrawImage(tex, &opts)
+		}
+	}
+}
+
+// atlasRows returns the number of texture strips packed in the atlas.
+func (dg *DualGrid) atlasRows() int {
+	return len(dg.Materials) + len(dg.Transitions)
+}
+
+// ensureAtlas rebuilds the atlas if materials were appended directly to dg.Materials.
+func (dg *DualGrid) ensureAtlas() {
+	if dg.atlas == nil || len(dg.worldIndex) != dg.atlasRows() {
+		dg.buildAtlas()
+	}
+}
+
+// dropAtlas releases the atlas, rebuilt on the next GPU render.
+func (dg *DualGrid) dropAtlas() {
+	if dg.atlas != nil {
+		dg.atlas.Deallocate()
+		dg.atlas = nil
+	}
+}
+
+// quadIndices returns indices for n consecutive quads (TL, TR, BL, BR vertex order).
+func (dg *DualGrid) quadIndices(n int) []uint16 {
+	for q := len(dg.indices) / 6; q < n; q++ {
+		base := uint16(q * 4)
+		dg.indices = append(dg.indices,
+			base, base+1, base+2,
+			base+1, base+3, base+2,
+		)
+	}
+	return dg.indices[:n*6]
+}
+
+// DrawTo clears img and renders the DualGrid into it from the given top-left world pixel coord.
+func (dg *DualGrid) DrawTo(img *ebiten.Image, left, top int) {
+	img.Clear()
+	dg.render(img, left, top)
+}
+
+// render draws into img with the selected Renderer.
+func (dg *DualGrid) render(img *ebiten.Image, left, top int) {
+	if dg.Renderer != nil {
+		dg.Renderer.Render(dg, img, left, top)
+		return
+	}
+	dg.renderTo(img, left, top)
+}
+
+// RedrawCanvasRegion clears and redraws the tile region at (tileX, tileY) with
+// size (tileW x tileH) on the DualGrid's internal canvas.
+// Automatically expands by one tile on the right/bottom for dual-grid corner overlap.
+func (dg *DualGrid) RedrawCanvasRegion(tileX, tileY, tileW, tileH int) {
+	left := tileX * dg.TileSize
+	top := tileY * dg.TileSize
+	right := (tileX + tileW + 1) * dg.TileSize
+	bottom := (tileY + tileH + 1) * dg.TileSize
+
+	if dg.canvas == nil {
+		return // the first Canvas() call draws everything
+	}
+	b := dg.canvas.Bounds()
+	left = max(left, b.Min.X)
+	top = max(top, b.Min.Y)
+	right = min(right, b.Max.X)
+	bottom = min(bottom, b.Max.Y)
+	if left >= right || top >= bottom {
+		return
+	}
+	sub := dg.canvas.SubImage(image.Rect(left, top, right, bottom)).(*ebiten.Image)
+	dg.render(sub, left, top)
+}
+
+func (dg *DualGrid) renderTo(img *ebiten.Image, left, top int) {
+	view := dg.newRenderView(img.Bounds(), left, top)
+
+	buildStart := time.Now()
+	_, capBefore := dg.vertexCapacity()
+
+	numMats := len(dg.Materials)
+	dg.ensureAtlas()
+
+	view.scales = dg.materialScales()
+
+	// Split the columns into one stripe per worker
+	stripes := min(max(dg.Workers, 1), max(view.widthInTile, 1))
+	if len(dg.stripes) < stripes {
+		dg.stripes = append(dg.stripes, make([][][]ebiten.Vertex, stripes-len(dg.stripes))...)
+	}
+	for s := range stripes {
+		// Reuse cached vertex buffers
+		if len(dg.stripes[s]) < numMats {
+			dg.stripes[s] = append(dg.stripes[s], make([][]ebiten.Vertex, numMats-len(dg.stripes[s]))...)
+		}
+		for i := range numMats {
+			dg.stripes[s][i] = dg.stripes[s][i][:0]
+		}
+	}
+	if stripes == 1 {
+		dg.buildStripe(dg.stripes[0], &view, 0, view.widthInTile)
+	} else {
+		var wg sync.WaitGroup
+		for s := range stripes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				dg.buildStripe(dg.stripes[s], &view, s*view.widthInTile/stripes, (s+1)*view.widthInTile/stripes)
+			}()
+		}
+		wg.Wait()
+	}
+
+	// Up to four layers overlap on a tile, each material batch drawing over the ones before it:
+	// concatenating the batches in priority order keeps the layering.
+	// Stripes are concatenated left to right, giving the same batch as a single stripe.
+	// Everything goes out in one draw call unless it exceeds what uint16 indices can address.
+	// Only the materials of the draw range go in, each ending at batchEnds[position-from].
+	_, order := dg.priorities()
+	from, to := dg.drawBounds(numMats)
+	dg.batch = dg.batch[:0]
+	dg.batchEnds = dg.batchEnds[:0]
+	dg.stats.Quads = slices.Grow(dg.stats.Quads[:0], numMats)[:numMats]
+	clear(dg.stats.Quads)
+	for _, i := range order[from:max(from, to)] {
+		for s := range stripes {
+			dg.batch = append(dg.batch, dg.stripes[s][i]...)
+			dg.stats.Quads[i] += len(dg.stripes[s][i]) / 4
+		}
+		dg.batchEnds = append(dg.batchEnds, len(dg.batch))
+	}
+
+	dg.stats.BuildTime = time.Since(buildStart)
+	dg.stats.VisibleTiles = dg.visibleTiles(&view)
+	emitted, capAfter := dg.vertexCapacity()
+	dg.stats.VerticesReused = min(capBefore, emitted)
+	dg.stats.VerticesAllocated = max(capAfter-capBefore, 0)
+	dg.stats.DrawCalls = 0
+
+	// Consecutive materials share one batch [start, end), only materials with a Shader or
+	// a world texture and the hook split it
+	var start, end int
+	for p, segEnd := range dg.batchEnds {
+		m := &dg.Materials[order[from+p]]
+		if m.Shader != nil || dg.usesWorld(order[from+p]) {
+			dg.drawBatch(img, dg.batch[start:end])
+			dg.drawShaderBatch(img, dg.batch[end:segEnd], m)
+			start = segEnd
+		}
+		end = segEnd
+		if dg.hook != nil {
+			dg.drawBatch(img, dg.batch[start:end])
+			dg.hook(img, from+p, order[from+p])
+			start = end
+		}
+	}
+	dg.drawBatch(img, dg.batch[start:end])
+}
+
+// drawShaderBatch draws the verts of material m with its Shader, or the world shader when it
+// has none, the atlas as imageSrc0 and the world texture of the quads as imageSrc1. Quads
+// of different world textures (transitions of m) go in separate calls, they never overlap.
+func (dg *DualGrid) drawShaderBatch(img *ebiten.Image, verts []ebiten.Vertex, m *Material) {
+	var drawOpts ebiten.DrawTrianglesShaderOptions
+	drawOpts.Images[0] = dg.atlas
+	drawOpts.Uniforms = m.Uniforms
+	drawOpts.Blend = dg.Options.Blend
+	shader := m.Shader
+	if shader == nil {
+		shader = worldMaskShader()
+	}
+	for k := 0; k <= len(dg.worlds) && len(verts) > 0; k++ {
+		// Quads of world texture k move to the front, the rest is left for the next ones
+		n := 0
+		for q := 0; q < len(verts); q += 4 {
+			if int(verts[q].Custom2) == k {
+				if q != n {
+					for c := range 4 {
+						verts[n+c], verts[q+c] = verts[q+c], verts[n+c]
+					}
+				}
+				n += 4
+			}
+		}
+		drawOpts.Images[1] = nil
+		if k > 0 {
+			drawOpts.Images[1] = dg.worlds[k-1]
+		}
+		for start := 0; start < n; start += maxBatchVertices {
+			end := min(start+maxBatchVertices, n)
+			img.DrawTrianglesShader(verts[start:end], dg.quadIndices((end-start)/4), shader, &drawOpts)
+			dg.stats.DrawCalls++
+		}
+		verts = verts[n:]
+	}
+}
+
+// drawBatch draws verts from the atlas, split in as few DrawTriangles calls as uint16 indices allow.
+func (dg *DualGrid) drawBatch(img *ebiten.Image, verts []ebiten.Vertex) {
+	drawOpts := ebiten.DrawTrianglesOptions{
+		ColorScaleMode: ebiten.ColorScaleModePremultipliedAlpha,
+		Blend:          dg.Options.Blend,
+		Filter:         dg.Options.Filter,
+		Address:        dg.Options.Address,
+	}
+	for start := 0; start < len(verts); start += maxBatchVertices {
+		end := min(start+maxBatchVertices, len(verts))
+		img.DrawTriangles(verts[start:end], dg.quadIndices((end-start)/4), dg.atlas, &drawOpts)
+		dg.stats.DrawCalls++
+	}
+}
+
+// buildStripe appends the quads of the view columns [x0, x1) to verts, one slice per material.
+// It only reads the DualGrid so stripes can be built concurrently.
+func (dg *DualGrid) buildStripe(verts [][]ebiten.Vertex, view *renderView, x0, x1 int) {
+	ts := float32(dg.TileSize)
+	half := ts / 2
+	dg.ResolveRect(view.tileStartX+x0, view.tileStartY, x1-x0, view.heightInTile, func(tileX, tileY int, layers []Layer) {
+		dstX := float32(tileX-view.tileStartX)*ts - view.offsetX + view.originX
+		dstY := float32(tileY-view.tileStartY)*ts - view.offsetY + view.originY
+		tl, tr, bl, br := dg.cornerTints(tileX, tileY)
+		// World pixel position in Custom0 and Custom1, for material shaders: dual-grid tiles
+		// sit half a tile up and left of the cells, see TileOffset
+		worldX, worldY := float32(tileX)*ts-half, float32(tileY)*ts-half
+
+		for _, l := range layers {
+			i := l.Material
+			srcX := float32(dg.layerMaterial(l).frameOffset(dg.Clock)+l.Slot) * ts
+			row := dg.atlasRow(l)
+			srcY := float32(row) * ts
+			// World texture in Custom2, for the world shader
+			world := float32(dg.worldIndex[row])
+			// Premultiplied vertex colors, tint times color scale
+			tl, tr, bl, br := tl.premultiplied().mul(view.scales[i]), tr.premultiplied().mul(view.scales[i]),
+				bl.premultiplied().mul(view.scales[i]), br.premultiplied().mul(view.scales[i])
+
+			// Oriented tiles permute the texture coords of the corners
+			uv := l.Orientation.corners()
+
+			// TL, TR, BL, BR
+			verts[i] = append(verts[i],
+				ebiten.Vertex{DstX: dstX, DstY: dstY, SrcX: srcX + uv[0][0]*ts, SrcY: srcY + uv[0][1]*ts, ColorR: tl.R, ColorG: tl.G, ColorB: tl.B, ColorA: tl.A,
+					Custom0: worldX, Custom1: worldY, Custom2: world},
+				ebiten.Vertex{DstX: dstX + ts, DstY: dstY, SrcX: srcX + uv[1][0]*ts, SrcY: srcY + uv[1][1]*ts, ColorR: tr.R, ColorG: tr.G, ColorB: tr.B, ColorA: tr.A,
+					Custom0: worldX + ts, Custom1: worldY, Custom2: world},
+				ebiten.Vertex{DstX: dstX, DstY: dstY + ts, SrcX: srcX + uv[2][0]*ts, SrcY: srcY + uv[2][1]*ts, ColorR: bl.R, ColorG: bl.G, ColorB: bl.B, ColorA: bl.A,
+					Custom0: worldX, Custom1: worldY + ts, Custom2: world},
+				ebiten.Vertex{DstX: dstX + ts, DstY: dstY + ts, SrcX: srcX + uv[3][0]*ts, SrcY: srcY + uv[3][1]*ts, ColorR: br.R, ColorG: br.G, ColorB: br.B, ColorA: br.A,
+					Custom0: worldX + ts, Custom1: worldY + ts, Custom2: world},
+			)
+		}
+	})
+}
+
+// vertexCapacity returns the total length and capacity of the cached vertex buffers.
+func (dg *DualGrid) vertexCapacity() (length, capacity int) {
+	length, capacity = len(dg.batch), cap(dg.batch)
+	for _, stripe := range dg.stripes {
+		for _, v := range stripe {
+			length += len(v)
+			capacity += cap(v)
+		}
+	}
+	return length, capacity
+}
@@ -20,6 +20,7 @@ var (
 	showCorners  bool
 	showGrid     bool
 	showTextures bool
+	showStats    bool
 
 	selectedMaterial int
 	materialsColors  []color.Color
@@ -107,6 +108,9 @@ func (g *Game) Update() error {
 	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
 		showTextures = !showTextures
 	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyI) {
+		showStats = !showStats
+	}
 
 	// Save
 	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
@@ -168,23 +172,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				}
 				// Display grid true value
 				if showCorners {
-					tl = g.DualGrid.DefaultMaterial
-					tr = g.DualGrid.DefaultMaterial
-					bl = g.DualGrid.DefaultMaterial
-					br = g.DualGrid.DefaultMaterial
-
-					if x >= 1 && y >= 1 {
-						tl = g.DualGrid.GetCell(x-1, y-1)
-					}
-					if x < g.DualGrid.WorldGrid.Width && y >= 1 {
-						tr = g.DualGrid.GetCell(x, y-1)
-					}
-					if x >= 1 && y < g.DualGrid.WorldGrid.Height {
-						bl = g.DualGrid.GetCell(x-1, y)
-					}
-					if x < g.DualGrid.WorldGrid.Width && y < g.DualGrid.WorldGrid.Height {
-						br = g.DualGrid.GetCell(x, y)
-					}
+					tl, tr, bl, br = g.DualGrid.Corners(x, y)
 
 					vector.DrawFilledCircle(screen, float32(sx)-co, float32(sy)-co, 1, materialsColors[tl], false)
 					vector.DrawFilledCircle(screen, float32(sx)+co, float32(sy)-co, 1, materialsColors[tr], false)
@@ -220,6 +208,15 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	opts.GeoM.Translate(1, float64(yPos))
 	screen.DrawImage(g.DualGrid.Materials[selectedMaterial].Texture.SubImage(image.Rect(15*g.DualGrid.TileSize, 0, 15*g.DualGrid.TileSize+g.DualGrid.TileSize, g.DualGrid.TileSize)).(*ebiten.Image), opts)
 
+	// Render stats (top right)
+	if showStats {
+		stats := g.DualGrid.Stats()
+		statsText := fmt.Sprintf("Tiles: %d\nQuads: %d %v\nDraw calls: %d\nVertices: %d new, %d reused\nBuild: %s",
+			stats.VisibleTiles, stats.TotalQuads(), stats.Quads, stats.DrawCalls,
+			stats.VerticesAllocated, stats.VerticesReused, stats.BuildTime)
+		ebitenutil.DebugPrintAt(screen, statsText, screen.Bounds().Dx()-200, 8)
+	}
+
 	// Mode indicator (bottom right)
 	modeText := "Mode: " + currentMode.GetName() + " [Tab]"
 	ebitenutil.DebugPrintAt(screen, modeText, screen.Bounds().Dx()-len(modeText)*6-8, screen.Bounds().Dy()-12-8)
@@ -259,6 +256,7 @@ func main() {
 		"  G                Display the grid\n",
 		"  C                Display the grid true values\n",
 		"  M                Display the computed materials\n",
+		"  I                Display the last render stats\n",
 	)
 
 	ebiten.SetWindowSize(worldWidth*2, worldHeight*2)
@@ -0,0 +1,302 @@
+package dualgrid
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+const testTileSize = 16
+
+// goldenTest is a DualGrid setup rendered from (left, top) and compared to testdata/<name>.png.
+type goldenTest struct {
+	name      string
+	setup     func(t *testing.T) DualGrid
+	left, top int
+}
+
+var goldenTests = []goldenTest{
+	{name: "simple", setup: simpleGrid},
+	{name: "simple_offset", setup: simpleGrid, left: -7, top: 13},
+	{name: "simple_priority", setup: simplePriorityGrid},
+	{name: "simple_tint", setup: simpleTintGrid},
+	{name: "simple_options", setup: simpleOptionsGrid},
+	{name: "simple_world", setup: simpleWorldGrid, left: -7, top: 13},
+	{name: "simple_shaded", setup: simpleShadedGrid},
+	{name: "dungeon", setup: dungeonGrid},
+	{name: "dungeon_terrain", setup: dungeonTerrainGrid},
+	{name: "nature", setup: natureGrid},
+	{name: "nature_orientation", setup: natureOrientationGrid},
+	{name: "nature_transition", setup: natureTransitionGrid},
+}
+
+// Golden renders go through the SoftwareRenderer so they run without a GPU, and in headless
+// builds without ebiten at all. TestVertexRendererMatchesSoftware compares the GPU to them.
+func TestGolden(t *testing.T) {
+	for _, tt := range goldenTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dg := tt.setup(t)
+			w := (dg.WorldGrid.Width + 1) * dg.TileSize
+			h := (dg.WorldGrid.Height + 1) * dg.TileSize
+			img := image.NewRGBA(image.Rect(0, 0, w, h))
+			dg.DrawToRGBA(img, tt.left, tt.top)
+			checkGolden(t, tt.name, img)
+		})
+	}
+}
+
+// checkGolden compares img to testdata/<name>.png, writing testdata/<name>_diff.png on mismatch.
+func checkGolden(t *testing.T, name string, img *image.RGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".png")
+	diffPath := filepath.Join("testdata", name+"_diff.png")
+	if *update {
+		writePNG(t, path, img)
+		os.Remove(diffPath)
+		return
+	}
+
+	want := toRGBA(loadPNG(t, path))
+	if want.Rect != img.Rect {
+		t.Fatalf("size mismatch: got %v, golden has %v", img.Rect.Size(), want.Rect.Size())
+	}
+
+	// Mismatching pixels in red over a faded copy of the golden image
+	diff := image.NewRGBA(img.Rect)
+	var mismatches int
+	for y := range img.Rect.Dy() {
+		for x := range img.Rect.Dx() {
+			got, exp := img.RGBAAt(x, y), want.RGBAAt(x, y)
+			if got != exp {
+				mismatches++
+				diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+			diff.SetRGBA(x, y, color.RGBA{exp.R / 4, exp.G / 4, exp.B / 4, 255})
+		}
+	}
+	if mismatches > 0 {
+		writePNG(t, diffPath, diff)
+		t.Errorf("%d pixels differ from %s, see %s (run with -update to accept)", mismatches, path, diffPath)
+		return
+	}
+	os.Remove(diffPath)
+}
+
+// simpleGrid is the diagonal bands of example/simple.
+func simpleGrid(t *testing.T) DualGrid {
+	dg := NewDualGrid(20, 14, testTileSize, 3)
+	addNatureMaterials(t, &dg)
+	for x := range 20 {
+		for y := range 14 {
+			d := x - y
+			switch {
+			case d >= 1 && d <= 5:
+				dg.SetCell(x, y, 0)
+			case d >= -4 && d <= 10:
+				dg.SetCell(x, y, 1)
+			case d >= -9 && d <= 15:
+				dg.SetCell(x, y, 2)
+			}
+		}
+	}
+	return dg
+}
+
+// simplePriorityGrid is simpleGrid drawn bottom to top as grass, dark grass, dirt then rock.
+func simplePriorityGrid(t *testing.T) DualGrid {
+	dg := simpleGrid(t)
+	dg.Priority = []TileType{3, 2, 1, 0}
+	return dg
+}
+
+// simpleTintGrid is simpleGrid darkening from left to right, with a red spot.
+func simpleTintGrid(t *testing.T) DualGrid {
+	dg := simpleGrid(t)
+	tints := NewTintGrid(dg.WorldGrid.Width, dg.WorldGrid.Height)
+	for x := range tints.Width {
+		l := 1 - float32(x)/float32(tints.Width)
+		tints.FillRect(x, 0, 1, tints.Height, Tint{l, l, l, 1})
+	}
+	tints.Set(5, 7, Tint{1, 0.2, 0.2, 1})
+	dg.Tint = &tints
+	return dg
+}
+
+// simpleOptionsGrid is simpleGrid with dirt hidden, dark grass flashing red and everything tinted blue.
+func simpleOptionsGrid(t *testing.T) DualGrid {
+	dg := simpleGrid(t)
+	dg.Materials[1].Hidden = true
+	dg.Materials[2].ColorScale.Scale(1, 0.4, 0.4, 1)
+	dg.Options.ColorScale.Scale(0.8, 0.8, 1, 1)
+	return dg
+}
+
+// simpleWorldGrid is simpleGrid with the rock band showing a world texture spanning the
+// first material types, wider than a tile and not a multiple of it.
+func simpleWorldGrid(t *testing.T) DualGrid {
+	dg := simpleGrid(t)
+	types := toRGBA(loadAsset(t, "materialTypes"))
+	texture := types.SubImage(image.Rect(0, 0, 40, testTileSize))
+	rock, err := NewMaterialFromMaskWorldImage(testTileSize, texture, loadAsset(t, "rockMask"), LayoutJess, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.Materials[0] = rock
+	return dg
+}
+
+// simpleShadedGrid is simplePriorityGrid with the rock band beveled by a shaded mask, lit from
+// the top left with a warm highlight. Rock is drawn last so its beveled edges show.
+func simpleShadedGrid(t *testing.T) DualGrid {
+	dg := simplePriorityGrid(t)
+	types := toRGBA(loadAsset(t, "materialTypes"))
+	texture := types.SubImage(image.Rect(0, 0, testTileSize, testTileSize))
+	mask := bevelMask(toRGBA(loadAsset(t, "rockMask")), true)
+	shading := MaskShading{Highlight: color.RGBA{255, 230, 180, 255}}
+	rock, err := NewMaterialFromShadedMaskImage(testTileSize, texture, mask, LayoutJess, shading, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.Materials[0] = rock
+	return dg
+}
+
+// dungeonGrid is the Dungeon mode of example/editor.
+func dungeonGrid(t *testing.T) DualGrid {
+	dg := NewDualGrid(16, 16, testTileSize, 2)
+	dg.WorldGrid.FillRect(4, 4, 8, 8, 0)  // main room
+	dg.WorldGrid.FillRect(4, 4, 8, 1, 1)  // main room wall
+	dg.WorldGrid.FillRect(6, 2, 4, 12, 0) // vertical corridor
+	dg.WorldGrid.FillRect(6, 2, 4, 1, 1)  // vertical corridor wall
+	dg.WorldGrid.FillRect(2, 6, 12, 4, 0) // horizontal corridor
+	dg.WorldGrid.FillRect(2, 6, 2, 1, 1)  // horizontal corridor left wall
+	dg.WorldGrid.FillRect(12, 6, 2, 1, 1) // horizontal corridor right wall
+
+	for _, name := range []string{"floor", "wall", "topWall"} {
+		mat, err := NewMaterialFromTilemapImage(testTileSize, loadAsset(t, name), VarientMap{})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		dg.AddMaterial(mat)
+	}
+	return dg
+}
+
+// dungeonTerrainGrid is dungeonGrid with wall and topWall connecting as one terrain.
+func dungeonTerrainGrid(t *testing.T) DualGrid {
+	dg := dungeonGrid(t)
+	dg.Materials[1].Terrain = 1
+	dg.Materials[2].Terrain = 1
+	return dg
+}
+
+// natureGrid is the Nature mode of example/editor, with a few patches of every material painted.
+func natureGrid(t *testing.T) DualGrid {
+	dg := NewDualGrid(16, 16, testTileSize, 3)
+	addNatureMaterials(t, &dg)
+	dg.WorldGrid.FillRect(1, 1, 6, 5, 0)
+	dg.WorldGrid.FillRect(3, 2, 2, 2, 1)
+	dg.WorldGrid.FillRect(8, 2, 6, 6, 2)
+	dg.WorldGrid.OutlineRect(2, 8, 12, 6, 4)
+	dg.WorldGrid.FillRect(5, 10, 4, 2, 1)
+	dg.SetCell(12, 12, 0)
+	return dg
+}
+
+// natureOrientationGrid is natureGrid with every full tile rotated and flipped, and the grass edges mirrored.
+func natureOrientationGrid(t *testing.T) DualGrid {
+	dg := natureGrid(t)
+	for i := range dg.Materials {
+		dg.Materials[i].Orientations[0b1111] = AllOrientations
+	}
+	dg.Materials[2].Orientations[0b1100] = FlipX
+	dg.Materials[2].Orientations[0b0011] = FlipX
+	return dg
+}
+
+// natureTransitionGrid is natureGrid with a dirt edge where grass meets rock.
+func natureTransitionGrid(t *testing.T) DualGrid {
+	dg := natureGrid(t)
+	types := toRGBA(loadAsset(t, "materialTypes"))
+	dirt := types.SubImage(image.Rect(testTileSize, 0, 2*testTileSize, testTileSize))
+	edge, err := NewMaterialFromMaskImage(testTileSize, dirt, loadAsset(t, "grassMask"), VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.AddTransition(3, 0, edge)
+	return dg
+}
+
+// addNatureMaterials adds the rock, dirt, dark grass, grass and green grass materials of the examples.
+func addNatureMaterials(t *testing.T, dg *DualGrid) {
+	types := loadAsset(t, "materialTypes").(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	texture := func(i int) image.Image {
+		return types.SubImage(image.Rect(i*testTileSize, 0, i*testTileSize+testTileSize, testTileSize))
+	}
+	mats := []struct {
+		texture    int
+		mask       string
+		varientMap VarientMap
+	}{
+		{0, "rockMask", VarientMap{}},
+		{1, "rockMask", VarientMap{}},
+		{2, "grassMask", VarientMap{3: {17}, 5: {16}, 10: {19}, 12: {18}}},
+		{3, "softMask", VarientMap{}},
+	}
+	for _, m := range mats {
+		mat, err := NewMaterialFromMaskImage(testTileSize, texture(m.texture), loadAsset(t, m.mask), m.varientMap)
+		if err != nil {
+			t.Fatal(m.mask, err)
+		}
+		dg.AddMaterial(mat)
+	}
+	greenGrass, err := NewMaterialFromTilemapImage(testTileSize, loadAsset(t, "grassTilemap"), VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.AddMaterial(greenGrass)
+}
+
+func loadAsset(t *testing.T, name string) image.Image {
+	t.Helper()
+	return loadPNG(t, filepath.Join("example", "assets", name+".png"))
+}
+
+func loadPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(fmt.Errorf("%s: %w", path, err))
+	}
+	return img
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
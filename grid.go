@@ -1,12 +1,17 @@
 package dualgrid
 
+import "image"
+
 type Grid struct {
 	Width, Height int
 	Cells         []TileType
+	// Cells changed by SetCell and the shape functions since the ShaderRenderer last
+	// uploaded them, see DualGrid.MarkDirty for direct Cells writes
+	changed image.Rectangle
 }
 
 func NewGrid(width, height int) Grid {
-	return Grid{Width: width, Height: height, Cells: make([]TileType, width*height)}
+	return Grid{Width: width, Height: height, Cells: make([]TileType, width*height), changed: image.Rect(0, 0, width, height)}
 }
 
 func NewGridWithValue(width, height int, value TileType) Grid {
@@ -16,7 +21,12 @@ func NewGridWithValue(width, height int, value TileType) Grid {
 			cells[i] = value
 		}
 	}
-	return Grid{Width: width, Height: height, Cells: cells}
+	return Grid{Width: width, Height: height, Cells: cells, changed: image.Rect(0, 0, width, height)}
+}
+
+// markChanged records the w x h cells at (x, y) as changed.
+func (g *Grid) markChanged(x, y, w, h int) {
+	g.changed = g.changed.Union(image.Rect(x, y, x+w, y+h))
 }
 
 // // Set sets the TileType at the given cell.
@@ -32,6 +42,7 @@ func NewGridWithValue(width, height int, value TileType) Grid {
 // FillRect fills a rectangle on the grid with the given value.
 // x, y is the top-left corner; w, h are width and height.
 func (g *Grid) FillRect(x, y, w, h int, value TileType) {
+	g.markChanged(x, y, w, h)
 	for dx := range w {
 		for dy := range h {
 			g.Cells[(x+dx)*g.Height+(y+dy)] = value
@@ -42,6 +53,7 @@ func (g *Grid) FillRect(x, y, w, h int, value TileType) {
 // OutlineRect draws the border of a rectangle on the grid with the given value.
 // x, y is the top-left corner; w, h are width and height.
 func (g *Grid) OutlineRect(x, y, w, h int, value TileType) {
+	g.markChanged(x, y, w, h)
 	for dx := range w {
 		g.Cells[(x+dx)*g.Height+y] = value
 		g.Cells[(x+dx)*g.Height+(y+h-1)] = value
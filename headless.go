@@ -0,0 +1,107 @@
+//go:build dualgrid_headless
+
+package dualgrid
+
+import "image/color"
+
+// Headless builds (go build -tags dualgrid_headless) leave out everything drawing with ebiten,
+// so the package runs where ebiten cannot initialize, e.g. a server without a display.
+// Grids, materials built from image.Image sources, Resolve and the SoftwareRenderer through
+// DrawToRGBA work the same as in ebiten builds.
+
+// MaterialEbiten holds the GPU resources of a Material, none in headless builds.
+type MaterialEbiten struct{}
+
+// DualGridEbiten holds the Renderer and GPU resources of a DualGrid, none in headless builds.
+type DualGridEbiten struct{}
+
+type layeredEbiten struct{}
+
+type softwareUpload struct{}
+
+// RenderOptions are the draw time options of a DualGrid, only ColorScale in headless builds.
+type RenderOptions struct {
+	// ColorScale of every material, on top of Material.ColorScale.
+	ColorScale ColorScale
+}
+
+// sourceOver reports whether tiles are blended source over, always in headless builds.
+func (o *RenderOptions) sourceOver() bool {
+	return true
+}
+
+// ColorScale is the premultiplied color scale of materials and RenderOptions, with the
+// methods of ebiten.ColorScale. The zero value is the identity scale (1, 1, 1, 1).
+type ColorScale struct {
+	// Offset by -1, so the zero value is the identity
+	r, g, b, a float32
+}
+
+// Reset resets the ColorScale as identity.
+func (c *ColorScale) Reset() {
+	*c = ColorScale{}
+}
+
+// R returns the red scale.
+func (c *ColorScale) R() float32 { return c.r + 1 }
+
+// G returns the green scale.
+func (c *ColorScale) G() float32 { return c.g + 1 }
+
+// B returns the blue scale.
+func (c *ColorScale) B() float32 { return c.b + 1 }
+
+// A returns the alpha scale.
+func (c *ColorScale) A() float32 { return c.a + 1 }
+
+// SetR overwrites the current red value with r.
+func (c *ColorScale) SetR(r float32) { c.r = r - 1 }
+
+// SetG overwrites the current green value with g.
+func (c *ColorScale) SetG(g float32) { c.g = g - 1 }
+
+// SetB overwrites the current blue value with b.
+func (c *ColorScale) SetB(b float32) { c.b = b - 1 }
+
+// SetA overwrites the current alpha value with a.
+func (c *ColorScale) SetA(a float32) { c.a = a - 1 }
+
+// Scale multiplies the given values to the current scale.
+func (c *ColorScale) Scale(r, g, b, a float32) {
+	c.SetR(c.R() * r)
+	c.SetG(c.G() * g)
+	c.SetB(c.B() * b)
+	c.SetA(c.A() * a)
+}
+
+// ScaleAlpha multiplies the given alpha value to the current scale.
+func (c *ColorScale) ScaleAlpha(a float32) {
+	c.Scale(a, a, a, a)
+}
+
+// ScaleWithColor multiplies the given color values to the current scale.
+func (c *ColorScale) ScaleWithColor(clr color.Color) {
+	r, g, b, a := clr.RGBA()
+	c.Scale(float32(r)/0xffff, float32(g)/0xffff, float32(b)/0xffff, float32(a)/0xffff)
+}
+
+// ScaleWithColorScale multiplies the given color scale values to the current scale.
+func (c *ColorScale) ScaleWithColorScale(colorScale ColorScale) {
+	c.Scale(colorScale.R(), colorScale.G(), colorScale.B(), colorScale.A())
+}
+
+// isWorld reports whether the material has a world texture.
+func (m *Material) isWorld() bool {
+	return m.WorldPixels != nil
+}
+
+// hasShader reports whether the material is drawn with its own Shader, never in headless builds.
+func (m *Material) hasShader() bool {
+	return false
+}
+
+// joinFrameTextures has no textures to join in headless builds.
+func (m *Material) joinFrameTextures(frames []Material) {}
+
+// dropAtlas has no atlas to release in headless builds.
+func (dg *DualGrid) dropAtlas() {}
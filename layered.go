@@ -0,0 +1,222 @@
+package dualgrid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"time"
+)
+
+// GridLayer is one DualGrid of a LayeredDualGrid, with its own materials.
+//
+// Upper layers usually use a TileType without a material (e.g. 255) as their
+// DefaultMaterial, so empty cells let the layers below show through.
+type GridLayer struct {
+	Name    string
+	Grid    DualGrid
+	Visible bool
+	Opacity float32 // 0 (hidden) to 1 (opaque)
+	// Visible and Opacity the canvas was last drawn with
+	drawnVisible bool
+	drawnOpacity float32
+}
+
+// LayeredDualGrid stacks DualGrids of the same size (ground, paths, decorations, roofs...),
+// drawn bottom to top in Layers order. The layers share one camera, canvas and dirty tracking.
+type LayeredDualGrid struct {
+	Width, Height int
+	TileSize      int
+	Layers        []*GridLayer
+	layeredEbiten
+	scratchRGBA *image.RGBA
+	dirty       bool
+}
+
+func NewLayeredDualGrid(width, height, tileSize int) LayeredDualGrid {
+	return LayeredDualGrid{
+		Width:    width,
+		Height:   height,
+		TileSize: tileSize,
+		dirty:    true,
+	}
+}
+
+// AddLayer appends a visible and opaque layer on top of the others and returns it,
+// ready for AddMaterial.
+func (ld *LayeredDualGrid) AddLayer(name string, defaultMaterial TileType) *GridLayer {
+	l := &GridLayer{
+		Name:    name,
+		Grid:    NewDualGrid(ld.Width, ld.Height, ld.TileSize, defaultMaterial),
+		Visible: true,
+		Opacity: 1,
+	}
+	ld.Layers = append(ld.Layers, l)
+	ld.dirty = true
+	return l
+}
+
+// Layer returns the layer with the given name, nil if there is none.
+func (ld *LayeredDualGrid) Layer(name string) *GridLayer {
+	for _, l := range ld.Layers {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// MarkDirty schedules a full canvas redraw on the next Canvas() call.
+// Call this after bulk modifications to any layer WorldGrid.
+// Changing a layer Visible or Opacity is picked up without it.
+func (ld *LayeredDualGrid) MarkDirty() {
+	ld.dirty = true
+}
+
+// AdvanceClock moves the animation clock of every layer forward by dt, see DualGrid.AdvanceClock.
+func (ld *LayeredDualGrid) AdvanceClock(dt time.Duration) {
+	for _, l := range ld.Layers {
+		l.Grid.AdvanceClock(dt)
+	}
+}
+
+// TileOffset returns the world pixel position of the ViewCanvasF canvas, see DualGrid.TileOffset.
+func (ld *LayeredDualGrid) TileOffset(worldLeft, worldTop float64) (x, y float64) {
+	return tileOffset(worldLeft, worldTop, ld.TileSize)
+}
+
+// DrawToRGBA clears dst and renders every visible layer into it on the CPU, see DualGrid.DrawToRGBA.
+func (ld *LayeredDualGrid) DrawToRGBA(dst *image.RGBA, left, top int) {
+	draw.Draw(dst, dst.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	var sr SoftwareRenderer
+	b := dst.Bounds()
+	for _, l := range ld.Layers {
+		if !l.Visible || l.Opacity <= 0 {
+			continue
+		}
+		if l.Opacity >= 1 {
+			sr.RenderRGBA(&l.Grid, dst, left, top)
+			continue
+		}
+
+		if ld.scratchRGBA == nil || ld.scratchRGBA.Rect != b {
+			ld.scratchRGBA = image.NewRGBA(b)
+		} else {
+			clear(ld.scratchRGBA.Pix)
+		}
+		sr.RenderRGBA(&l.Grid, ld.scratchRGBA, left, top)
+		drawScaled(dst, ld.scratchRGBA, l.Opacity)
+	}
+}
+
+// drawScaled composites src over dst (same bounds) with every channel scaled by alpha,
+// like drawing with ColorScale.ScaleAlpha.
+func drawScaled(dst, src *image.RGBA, alpha float32) {
+	a := uint32(alpha*255 + 0.5)
+	for i := 0; i < len(src.Pix); i += 4 {
+		s := src.Pix[i : i+4 : i+4]
+		d := dst.Pix[i : i+4 : i+4]
+		sa := (uint32(s[3])*a + 127) / 255
+		inv := 255 - sa
+		for c := range 3 {
+			sc := (uint32(s[c])*a + 127) / 255
+			d[c] = uint8(min(sc+(uint32(d[c])*inv+127)/255, 255))
+		}
+		d[3] = uint8(min(sa+(uint32(d[3])*inv+127)/255, 255))
+	}
+}
+
+// Marshal encodes every layer, with its name, visibility and opacity, to bytes.
+// Names are truncated to 255 bytes.
+//
+//	Format: [numLayers uint8] then per layer
+//	        [nameLen uint8][name][visible uint8][opacity float32][gridLen uint32][DualGrid.Marshal()]
+func (ld *LayeredDualGrid) Marshal() []byte {
+	buf := []byte{byte(len(ld.Layers))}
+	for _, l := range ld.Layers {
+		grid := l.Grid.Marshal()
+		name := l.Name[:min(len(l.Name), 255)]
+		buf = append(buf, byte(len(name)))
+		buf = append(buf, name...)
+		if l.Visible {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(l.Opacity))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(grid)))
+		buf = append(buf, grid...)
+	}
+	return buf
+}
+
+// Unmarshal loads every layer from bytes produced by Marshal. The layers must match the
+// current ones by count and name, each is checked like DualGrid.Unmarshal.
+// Nothing is modified when an error is returned.
+//
+// If forceResize is true, a grid size mismatch is not an error: every layer is resized instead.
+func (ld *LayeredDualGrid) Unmarshal(data []byte, forceResize bool) error {
+	if len(data) < 1 {
+		return errors.New("data too short")
+	}
+	if int(data[0]) != len(ld.Layers) {
+		return fmt.Errorf("layer count mismatch: file has %d, current is %d", data[0], len(ld.Layers))
+	}
+	data = data[1:]
+
+	type loaded struct {
+		visible bool
+		opacity float32
+		grid    DualGrid
+	}
+	layers := make([]loaded, len(ld.Layers))
+	for i, l := range ld.Layers {
+		if len(data) < 1 || len(data) < 1+int(data[0])+9 {
+			return errors.New("data truncated")
+		}
+		name := string(data[1 : 1+data[0]])
+		data = data[1+len(name):]
+		if name != l.Name {
+			return fmt.Errorf("layer %d name mismatch: file has %q, current is %q", i, name, l.Name)
+		}
+		layers[i].visible = data[0] != 0
+		layers[i].opacity = math.Float32frombits(binary.LittleEndian.Uint32(data[1:5]))
+		gridLen := int(binary.LittleEndian.Uint32(data[5:9]))
+		data = data[9:]
+		if len(data) < gridLen {
+			return errors.New("data truncated")
+		}
+
+		// Load into a copy sharing nothing with the layer WorldGrid
+		layers[i].grid = DualGrid{
+			TileSize:  l.Grid.TileSize,
+			Materials: l.Grid.Materials,
+			WorldGrid: NewGrid(l.Grid.WorldGrid.Width, l.Grid.WorldGrid.Height),
+		}
+		if err := layers[i].grid.Unmarshal(data[:gridLen], forceResize); err != nil {
+			return fmt.Errorf("layer %q: %w", name, err)
+		}
+		data = data[gridLen:]
+	}
+	for _, l := range layers[1:] {
+		if l.grid.WorldGrid.Width != layers[0].grid.WorldGrid.Width || l.grid.WorldGrid.Height != layers[0].grid.WorldGrid.Height {
+			return errors.New("layers have different grid sizes")
+		}
+	}
+
+	for i, l := range ld.Layers {
+		l.Visible = layers[i].visible
+		l.Opacity = layers[i].opacity
+		l.Grid.WorldGrid = layers[i].grid.WorldGrid
+		l.Grid.DefaultMaterial = layers[i].grid.DefaultMaterial
+		l.Grid.dirty = true
+	}
+	if len(layers) > 0 {
+		ld.Width = layers[0].grid.WorldGrid.Width
+		ld.Height = layers[0].grid.WorldGrid.Height
+	}
+	ld.dirty = true
+	return nil
+}
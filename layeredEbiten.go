@@ -0,0 +1,111 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// layeredEbiten is the canvas of a LayeredDualGrid.
+type layeredEbiten struct {
+	canvas  *ebiten.Image
+	scratch *ebiten.Image // a layer drawn alone before being blended with its Opacity
+}
+
+// isDirty reports whether the canvas is out of date, clearing every layer dirty flag.
+func (ld *LayeredDualGrid) isDirty() bool {
+	dirty := ld.dirty
+	for _, l := range ld.Layers {
+		if l.Grid.dirty || l.Visible != l.drawnVisible || l.Opacity != l.drawnOpacity {
+			dirty = true
+		}
+		l.Grid.dirty = false
+	}
+	ld.dirty = false
+	return dirty
+}
+
+// Canvas returns the cached full-grid rendered image of every visible layer, rebuilding it if dirty.
+func (ld *LayeredDualGrid) Canvas() *ebiten.Image {
+	fullW := (ld.Width + 1) * ld.TileSize
+	fullH := (ld.Height + 1) * ld.TileSize
+	dirty := ld.isDirty()
+	if ld.canvas == nil || ld.canvas.Bounds().Dx() != fullW || ld.canvas.Bounds().Dy() != fullH {
+		if ld.canvas != nil {
+			ld.canvas.Deallocate()
+		}
+		ld.canvas = ebiten.NewImage(fullW, fullH)
+		dirty = true
+	}
+	if dirty {
+		ld.DrawTo(ld.canvas, 0, 0)
+	}
+	return ld.canvas
+}
+
+// ViewCanvas renders only the visible world region of every visible layer, see DualGrid.ViewCanvas.
+func (ld *LayeredDualGrid) ViewCanvas(viewW, viewH, worldLeft, worldTop int) *ebiten.Image {
+	if ld.canvas == nil || ld.canvas.Bounds().Dx() != viewW || ld.canvas.Bounds().Dy() != viewH {
+		if ld.canvas != nil {
+			ld.canvas.Deallocate()
+		}
+		ld.canvas = ebiten.NewImage(viewW, viewH)
+	}
+	ld.DrawTo(ld.canvas, worldLeft, worldTop)
+	return ld.canvas
+}
+
+// ViewCanvasF is ViewCanvas for a viewport at fractional world pixels, see DualGrid.ViewCanvasF.
+func (ld *LayeredDualGrid) ViewCanvasF(viewW, viewH, worldLeft, worldTop float64) *ebiten.Image {
+	w, h := viewCanvasSize(viewW, viewH, ld.TileSize)
+	return ld.ViewCanvas(w, h, int(math.Floor(worldLeft)), int(math.Floor(worldTop)))
+}
+
+// RedrawCanvasRegion clears and redraws the tile region of every layer on the internal canvas,
+// see DualGrid.RedrawCanvasRegion.
+func (ld *LayeredDualGrid) RedrawCanvasRegion(tileX, tileY, tileW, tileH int) {
+	if ld.canvas == nil {
+		return // the first Canvas() call draws everything
+	}
+	r := image.Rect(tileX*ld.TileSize, tileY*ld.TileSize, (tileX+tileW+1)*ld.TileSize, (tileY+tileH+1)*ld.TileSize)
+	r = r.Intersect(ld.canvas.Bounds())
+	if r.Empty() {
+		return
+	}
+	ld.DrawTo(ld.canvas.SubImage(r).(*ebiten.Image), r.Min.X, r.Min.Y)
+}
+
+// DrawTo clears img and renders every visible layer into it from the given top-left world pixel coord.
+func (ld *LayeredDualGrid) DrawTo(img *ebiten.Image, left, top int) {
+	img.Clear()
+	b := img.Bounds()
+	for _, l := range ld.Layers {
+		l.drawnVisible, l.drawnOpacity = l.Visible, l.Opacity
+		if !l.Visible || l.Opacity <= 0 {
+			continue
+		}
+		if l.Opacity >= 1 {
+			l.Grid.render(img, left, top)
+			continue
+		}
+
+		// Draw the layer alone so its own overlapping tiles are not blended with each other
+		if ld.scratch == nil || ld.scratch.Bounds().Dx() < b.Dx() || ld.scratch.Bounds().Dy() < b.Dy() {
+			if ld.scratch != nil {
+				ld.scratch.Deallocate()
+			}
+			ld.scratch = ebiten.NewImage(b.Dx(), b.Dy())
+		}
+		layer := ld.scratch.SubImage(image.Rect(0, 0, b.Dx(), b.Dy())).(*ebiten.Image)
+		layer.Clear()
+		l.Grid.render(layer, left, top)
+
+		var opts ebiten.DrawImageOptions
+		opts.GeoM.Translate(float64(b.Min.X), float64(b.Min.Y))
+		opts.ColorScale.ScaleAlpha(l.Opacity)
+		img.DrawImage(layer, &opts)
+	}
+}
@@ -0,0 +1,63 @@
+package dualgrid
+
+import (
+	"image"
+	"testing"
+)
+
+// layeredGrid is natureGrid under a half transparent dungeon layer and a hidden one.
+func layeredGrid(t *testing.T) LayeredDualGrid {
+	ld := NewLayeredDualGrid(16, 16, testTileSize)
+	ground := ld.AddLayer("ground", 3)
+	ground.Grid = natureGrid(t)
+
+	walls := ld.AddLayer("walls", 255)
+	for _, name := range []string{"floor", "wall"} {
+		mat, err := NewMaterialFromTilemapImage(testTileSize, loadAsset(t, name), VarientMap{})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		walls.Grid.AddMaterial(mat)
+	}
+	walls.Grid.WorldGrid.FillRect(6, 6, 6, 6, 0)
+	walls.Grid.WorldGrid.OutlineRect(6, 6, 6, 6, 1)
+	walls.Opacity = 0.5
+
+	hidden := ld.AddLayer("hidden", 0)
+	hidden.Grid.AddMaterial(ground.Grid.Materials[0])
+	hidden.Visible = false
+	return ld
+}
+
+func TestLayeredGolden(t *testing.T) {
+	ld := layeredGrid(t)
+	img := image.NewRGBA(image.Rect(0, 0, (ld.Width+1)*ld.TileSize, (ld.Height+1)*ld.TileSize))
+	ld.DrawToRGBA(img, 0, 0)
+	checkGolden(t, "layered", img)
+}
+
+func TestLayeredMarshal(t *testing.T) {
+	src := layeredGrid(t)
+	src.Layers[1].Grid.SetCell(0, 0, 1)
+	data := src.Marshal()
+
+	dst := layeredGrid(t)
+	if err := dst.Unmarshal(data, false); err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range dst.Layers {
+		s := src.Layers[i]
+		if l.Visible != s.Visible || l.Opacity != s.Opacity || string(l.Grid.Marshal()) != string(s.Grid.Marshal()) {
+			t.Errorf("layer %q was not restored", l.Name)
+		}
+	}
+
+	dst.Layers[2].Name = "roofs"
+	dst.Layers[1].Grid.SetCell(1, 1, 1)
+	if err := dst.Unmarshal(data, false); err == nil {
+		t.Error("Unmarshal with a renamed layer succeeded")
+	}
+	if dst.Layers[1].Grid.GetCell(1, 1) != 1 {
+		t.Error("failed Unmarshal modified a layer")
+	}
+}
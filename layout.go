@@ -0,0 +1,161 @@
+package dualgrid
+
+import (
+	"errors"
+	"image"
+)
+
+var TilemapLayoutError = errors.New("Tilemap layout is invalid")
+
+// TilemapLayout describes where each bitmask tile sits in a tilemap (or mask) image.
+//
+//	Columns:
+//		Tiles per row of the image. Tiles are indexed row by row, index = row*Columns + column,
+//		VarientMap entries use the same indices.
+//
+//	Tiles:
+//		Tiles[bitmask] is the index of the bitmask tile, -1 when the image does not have it
+//		(the slot is left transparent, e.g. the empty tile 0).
+//
+//	Orientations:
+//		Orientations[bitmask] rotates or flips the tile when copied into the bitmask slot,
+//		so one tile can serve several bitmasks (see Expand). Masks are oriented before the
+//		texture is stamped through them, the texture keeps its orientation.
+type TilemapLayout struct {
+	Columns      int
+	Tiles        [16]int
+	Orientations [16]Orientation
+}
+
+var (
+	// LayoutJess is the 4x4 wang layout of Jess's dual-grid tutorial, each tile connecting
+	// to its neighbours in the image. Used by the constructors without a layout.
+	LayoutJess = NewTilemapLayout(4,
+		2, 5, 11, 3,
+		9, 7, 15, 14,
+		4, 12, 13, 10,
+		0, 1, 6, 8,
+	)
+	// LayoutBitmaskGrid is a 4x4 image holding the tiles in bitmask order, row by row.
+	LayoutBitmaskGrid = NewTilemapLayout(4, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15)
+	// LayoutBitmaskStrip is a 16x1 strip holding the tiles in bitmask order.
+	LayoutBitmaskStrip = NewTilemapLayout(16, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15)
+	// LayoutBitmaskStrip15 is a 15x1 strip holding the tiles 1 to 15 in bitmask order,
+	// without the empty tile.
+	LayoutBitmaskStrip15 = NewTilemapLayout(15, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15)
+	// LayoutMinimal is a 5x1 strip of the unique tiles of a symmetric tileset, expanded to
+	// every bitmask but the empty one: outer corner 0b0001 (bottom right), edge 0b0011
+	// (bottom), inner corner 0b0111 (all but top left), diagonal 0b1001 and full 0b1111.
+	LayoutMinimal = NewTilemapLayout(5, 1, 3, 7, 9, 15).Expand()
+)
+
+// NewTilemapLayout returns the layout of an image columns tiles wide, bitmasks being the bitmask
+// of each tile in reading order, -1 for tiles to ignore. Bitmasks not listed are left empty:
+//
+//	// 3 columns, empty tile and corners on the first row, ...
+//	layout := dualgrid.NewTilemapLayout(3, 0, 8, 4, ...)
+//
+// A bitmask listed twice keeps its first tile.
+func NewTilemapLayout(columns int, bitmasks ...int) TilemapLayout {
+	l := TilemapLayout{Columns: columns}
+	for i := range l.Tiles {
+		l.Tiles[i] = -1
+	}
+	for tile, bitmask := range bitmasks {
+		if bitmask >= 0 && bitmask < 16 && l.Tiles[bitmask] < 0 {
+			l.Tiles[bitmask] = tile
+		}
+	}
+	return l
+}
+
+// Expand returns the layout with every bitmask it lacks taken from a listed tile of the same
+// shape, rotated or flipped (rotations first). A few base tiles then cover all 16 bitmasks:
+//
+//	layout := dualgrid.NewTilemapLayout(6, 0, 1, 3, 7, 9, 15).Expand()
+//
+// Only tiles listed without an Orientation are used as sources. Bitmasks whose shape is not
+// listed stay empty.
+func (l TilemapLayout) Expand() TilemapLayout {
+	listed := l
+	for bitmask := range 16 {
+		if l.Tiles[bitmask] >= 0 {
+			continue
+		}
+		for o := range Orientation(8) {
+			src := o.sourceBitmask(bitmask)
+			if listed.Tiles[src] >= 0 && listed.Orientations[src] == OrientIdentity {
+				l.Tiles[bitmask] = listed.Tiles[src]
+				l.Orientations[bitmask] = o
+				break
+			}
+		}
+	}
+	return l
+}
+
+// flatten returns l with every oriented bitmask pointing at a new tile numbered from tiles on,
+// in the order of the returned bitmasks, for images that get those tiles appended.
+func (l TilemapLayout) flatten(tiles int) (flat TilemapLayout, oriented []int) {
+	flat = l
+	for bitmask, o := range l.Orientations {
+		if o == OrientIdentity || l.Tiles[bitmask] < 0 {
+			continue
+		}
+		flat.Tiles[bitmask] = tiles + len(oriented)
+		flat.Orientations[bitmask] = OrientIdentity
+		oriented = append(oriented, bitmask)
+	}
+	return flat, oriented
+}
+
+// flattenImage returns img with the oriented tiles of l appended as new rows, and the layout
+// pointing at them, see flatten.
+func (l TilemapLayout) flattenImage(img *image.RGBA, tileSize int) (*image.RGBA, TilemapLayout) {
+	rows := img.Rect.Dy() / tileSize
+	flat, oriented := l.flatten(rows * l.Columns)
+	if len(oriented) == 0 {
+		return img, l
+	}
+	extra := (len(oriented) + l.Columns - 1) / l.Columns
+	grown := image.NewRGBA(image.Rect(0, 0, img.Rect.Dx(), (rows+extra)*tileSize))
+	copy(grown.Pix, img.Pix)
+	for _, bitmask := range oriented {
+		src := l.tileRect(l.Tiles[bitmask], tileSize).Min
+		orientTile(grown, flat.tileRect(flat.Tiles[bitmask], tileSize).Min, img, src, tileSize, l.Orientations[bitmask])
+	}
+	return grown, flat
+}
+
+// rows returns the number of tile rows the 16 bitmask tiles span.
+func (l *TilemapLayout) rows() int {
+	var last int
+	for _, tile := range l.Tiles {
+		last = max(last, tile)
+	}
+	return last/l.Columns + 1
+}
+
+// check validates the layout and that every tile of sources lies inside an image of size b,
+// returning sizeErr when it does not.
+func (l *TilemapLayout) check(tileSize int, b image.Rectangle, sources []int, sizeErr error) error {
+	if l.Columns <= 0 {
+		return TilemapLayoutError
+	}
+	if b.Dx() != l.Columns*tileSize || b.Dy() < l.rows()*tileSize || b.Dy()%tileSize != 0 {
+		return sizeErr
+	}
+	for _, tile := range sources {
+		if tile >= l.Columns*(b.Dy()/tileSize) {
+			return sizeErr
+		}
+	}
+	return nil
+}
+
+// tileRect returns the pixel rect of a tile of the image.
+func (l *TilemapLayout) tileRect(tile, tileSize int) image.Rectangle {
+	x := (tile % l.Columns) * tileSize
+	y := (tile / l.Columns) * tileSize
+	return image.Rect(x, y, x+tileSize, y+tileSize)
+}
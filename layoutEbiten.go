@@ -0,0 +1,26 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// flattenEbitenImage is flattenImage for an ebiten.Image, the returned image is new when the
+// layout has oriented tiles.
+func (l TilemapLayout) flattenEbitenImage(img *ebiten.Image, tileSize int) (*ebiten.Image, TilemapLayout) {
+	b := img.Bounds()
+	rows := b.Dy() / tileSize
+	flat, oriented := l.flatten(rows * l.Columns)
+	if len(oriented) == 0 {
+		return img, l
+	}
+	extra := (len(oriented) + l.Columns - 1) / l.Columns
+	grown := ebiten.NewImage(b.Dx(), (rows+extra)*tileSize)
+	var opts ebiten.DrawImageOptions
+	opts.GeoM.Translate(float64(-b.Min.X), float64(-b.Min.Y))
+	grown.DrawImage(img, &opts)
+	for _, bitmask := range oriented {
+		src := l.tileRect(l.Tiles[bitmask], tileSize).Min.Add(b.Min)
+		drawOriented(grown, flat.tileRect(flat.Tiles[bitmask], tileSize).Min, img, src, tileSize, l.Orientations[bitmask])
+	}
+	return grown, flat
+}
@@ -0,0 +1,103 @@
+package dualgrid
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+)
+
+// The same tiles rearranged in every preset layout build the same material.
+func TestTilemapLayout(t *testing.T) {
+	jess := toRGBA(loadAsset(t, "grassTilemap"))
+	want, err := NewMaterialFromTilemapImage(testTileSize, jess, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, layout := range map[string]TilemapLayout{
+		"grid":    LayoutBitmaskGrid,
+		"strip":   LayoutBitmaskStrip,
+		"strip15": LayoutBitmaskStrip15,
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, layout.Columns*testTileSize, layout.rows()*testTileSize))
+		for bitmask, tile := range layout.Tiles {
+			if tile >= 0 {
+				draw.Draw(img, layout.tileRect(tile, testTileSize), jess, LayoutJess.tileRect(LayoutJess.Tiles[bitmask], testTileSize).Min, draw.Src)
+			}
+		}
+		got, err := NewMaterialFromTilemapImageLayout(testTileSize, img, layout, VarientMap{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for bitmask, tile := range layout.Tiles {
+			r := image.Rect(bitmask*testTileSize, 0, (bitmask+1)*testTileSize, testTileSize)
+			wantTile := want.Pixels.SubImage(r).(*image.RGBA)
+			if tile < 0 {
+				wantTile = image.NewRGBA(r) // left transparent
+			}
+			if !bytes.Equal(tilePix(got.Pixels.SubImage(r).(*image.RGBA)), tilePix(wantTile)) {
+				t.Errorf("%s: slot %d differs from the Jess layout", name, bitmask)
+			}
+		}
+	}
+
+	if _, err := NewMaterialFromTilemapImageLayout(testTileSize, jess, LayoutBitmaskStrip, VarientMap{}); !errors.Is(err, TilemapDimensionError) {
+		t.Errorf("4x4 image with a strip layout: err = %v, want TilemapDimensionError", err)
+	}
+	if _, err := NewMaterialFromTilemapImageLayout(testTileSize, jess, TilemapLayout{}, VarientMap{}); !errors.Is(err, TilemapLayoutError) {
+		t.Errorf("zero layout: err = %v, want TilemapLayoutError", err)
+	}
+}
+
+// tilePix returns the pixels of img row by row.
+func tilePix(img *image.RGBA) []byte {
+	var pix []byte
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		pix = append(pix, img.Pix[img.PixOffset(img.Rect.Min.X, y):img.PixOffset(img.Rect.Max.X, y)]...)
+	}
+	return pix
+}
+
+// A minimal tileset expanded by rotation gives every bitmask its shape, for tilemaps and masks.
+func TestLayoutMinimal(t *testing.T) {
+	const ts = testTileSize
+	// quadrant drawn for each set corner bit, the shape of the bitmask
+	shape := func(img *image.RGBA, at image.Point, bitmask int) {
+		for corner := range 4 {
+			if bitmask>>(3-corner)&1 == 1 {
+				r := image.Rect(corner%2*ts/2, corner/2*ts/2, (corner%2+1)*ts/2, (corner/2+1)*ts/2).Add(at)
+				draw.Draw(img, r, image.White, image.Point{}, draw.Src)
+			}
+		}
+	}
+	strip := func(layout TilemapLayout, bitmasks ...int) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, layout.Columns*ts, ts))
+		for i, bitmask := range bitmasks {
+			shape(img, image.Pt(i*ts, 0), bitmask)
+		}
+		return img
+	}
+	minimal := strip(LayoutMinimal, 1, 3, 7, 9, 15)
+	full := strip(LayoutBitmaskStrip, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15)
+
+	tilemap, err := NewMaterialFromTilemapImageLayout(ts, minimal, LayoutMinimal, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := NewMaterialFromTilemapImageLayout(ts, full, LayoutBitmaskStrip, VarientMap{})
+	if !bytes.Equal(tilemap.Pixels.Pix, want.Pixels.Pix) {
+		t.Errorf("minimal tilemap slots do not match their bitmask shapes")
+	}
+
+	texture := toRGBA(loadAsset(t, "materialTypes")).SubImage(image.Rect(0, 0, ts, ts))
+	masked, err := NewMaterialFromMaskImageLayout(ts, texture, minimal, LayoutMinimal, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ = NewMaterialFromMaskImageLayout(ts, texture, full, LayoutBitmaskStrip, VarientMap{})
+	if !bytes.Equal(masked.Pixels.Pix, want.Pixels.Pix) {
+		t.Errorf("minimal mask slots do not match a full mask, the texture must keep its orientation")
+	}
+}
@@ -3,8 +3,8 @@ package dualgrid
 import (
 	"errors"
 	"image"
-
-	"github.com/hajimehoshi/ebiten/v2"
+	"image/draw"
+	"time"
 )
 
 var (
@@ -18,11 +18,104 @@ var (
 //	Texture:
 //		Is an horizontal strip where each "slot" is TileSize wide
 //		First 16 slots are the computed texture, followed by any variant tiles.
+//
+//	Pixels:
+//		CPU copy of the same strip, only set for materials built from image.Image sources
+//		(NewMaterialFromTilemapImage, NewMaterialFromMaskImage). Texture is then created
+//		from it on the first GPU render, and the SoftwareRenderer can draw the material.
+//
+//	WorldTexture, WorldPixels:
+//		Texture tiled across the whole map in world space instead of once per tile, Texture
+//		then holding the mask tiles cutting it (see NewMaterialFromMaskWorld).
+//		WorldPixels is its CPU copy, like Pixels.
+//
+//	VarientWeights:
+//		Relative weight of each VarientMap entry, the first entry of a bitmask being its
+//		default tile. Missing weights count as 1, so nil picks variants uniformly.
+//
+//	Orientations:
+//		Rotations and flips each bitmask may be drawn with, picked per tile for variety
+//		without extra slots. Only the ones keeping the tile shape are used: any for the full
+//		tile 15, FlipX for the top edge 12 (0b1100), 1<<OrientTranspose for 9 (0b1001)...
+//
+//	Z:
+//		Draw priority, higher Z draws on top of lower Z. Materials with the same Z are
+//		ordered by index. Overridden by DualGrid.Priority.
+//
+//	Terrain:
+//		Terrain group, 0 for none. Materials of the same group connect to each other as one
+//		terrain: each draws over the others' corners instead of showing an edge against them.
+//		Overridden by DualGrid.Connects.
+//
+//	Frames, FrameDuration:
+//		Animated materials hold Frames strips of TileCount slots one after the other in
+//		Texture, each shown for FrameDuration at the DualGrid Clock (see NewAnimatedMaterial).
+//		0 or 1 frame is a still material.
+//
+//	Shader, Uniforms:
+//		Optional Kage shader the VertexRenderer draws the material with (DrawTrianglesShader),
+//		instead of plain DrawTriangles. imageSrc0 is the material atlas and srcPos the texel of
+//		the tile (use //kage:unit pixels), color the premultiplied tint and color scale and
+//		custom.xy the world pixel position, cell (0, 0) covering [0, TileSize) (see TileOffset).
+//		World materials also get their world texture as imageSrc1, custom.z being non zero.
+//		Other renderers draw the texture without it.
+//
+//	ColorScale, Hidden:
+//		Draw time color scale of the material (hit flashes, fading) and visibility toggle.
+//		A Hidden material is not drawn but still shapes the edges of the others.
+//
+//	Opaque:
+//		One entry per slot, true when every pixel of that slot has full alpha (in every frame,
+//		and in the world texture).
+//		Lower layers under an opaque slot are skipped when rendering, unless the material has
+//		a Shader, which may draw it translucent.
+//		Set by the image.Image constructors. Materials built from ebiten images get it on
+//		the first GPU render of their DualGrid, ebiten only reading pixels back once the game
+//		runs: until then it is nil and nothing is culled under them.
 type Material struct {
-	TileSize   int
-	TileCount  int
-	Texture    *ebiten.Image
-	VarientMap VarientMap
+	TileSize       int
+	TileCount      int
+	Pixels         *image.RGBA
+	WorldPixels    *image.RGBA
+	VarientMap     VarientMap
+	VarientWeights [16][]int
+	Orientations   [16]Orientations
+	Z              int
+	Terrain        int
+	Opaque         []bool
+
+	Frames        int
+	FrameDuration time.Duration
+
+	// Texture, WorldTexture, Shader and Uniforms
+	MaterialEbiten
+
+	ColorScale ColorScale
+	Hidden     bool
+}
+
+// isOpaque reports whether the given texture slot is known to be fully opaque.
+func (m *Material) isOpaque(slot int) bool {
+	return slot < len(m.Opaque) && m.Opaque[slot]
+}
+
+// slotOpacity reports for each slot of a texture strip whether all its pixels have full alpha.
+func slotOpacity(pix []byte, stride, tileSize, tileCount int) []bool {
+	opaque := make([]bool, tileCount)
+	for slot := range opaque {
+		opaque[slot] = true
+	slotLoop:
+		for y := range tileSize {
+			row := y*stride + 4*slot*tileSize
+			for x := range tileSize {
+				if pix[row+4*x+3] != 0xff {
+					opaque[slot] = false
+					break slotLoop
+				}
+			}
+		}
+	}
+	return opaque
 }
 
 // VarientMap maps a bitmask index (0–15) to a list of alternate tile indices within
@@ -37,102 +130,131 @@ type Material struct {
 //	}
 type VarientMap [16][]int
 
-// NewMaterialFromTilemap takes a 4x4 tilemap and builds a Material.
-func NewMaterialFromTilemap(tileSize int, tilemapImage *ebiten.Image, varientMap VarientMap) (Material, error) {
-	if tilemapImage.Bounds().Dx() != 4*tileSize || tilemapImage.Bounds().Dy() < 4*tileSize {
-		return Material{}, TilemapDimensionError
-	}
-	if tilemapImage.Bounds().Dy() > 4*tileSize && tilemapImage.Bounds().Dy()%tileSize != 0 {
-		return Material{}, TilemapDimensionError
-	}
-
-	var varientCount int
-	for _, v := range varientMap {
-		varientCount += len(v)
-	}
-
-	m := Material{}
-	m.TileCount = 16 + varientCount
-	m.Texture = ebiten.NewImage(m.TileCount*tileSize, tileSize)
-	m.VarientMap = VarientMap{}
-
-	// reorder tiles for bitmap indexing (different order from what i found online, dont remember why)
-	tileOrder := []int{2, 5, 11, 3, 9, 7, 15, 14, 4, 12, 13, 10, 0, 1, 6, 8}
-	var opts ebiten.DrawImageOptions
-	for i := range 16 {
-		x := (i % 4) * tileSize
-		y := (i / 4) * tileSize
-
-		opts.GeoM.Reset()
-		opts.GeoM.Translate(float64(tileOrder[i]*tileSize), 0)
-
-		m.Texture.DrawImage(tilemapImage.SubImage(image.Rect(x, y, x+tileSize, y+tileSize)).(*ebiten.Image), &opts)
-	}
-	var i = 16
+// tilemapLayout returns the tilemap tile index copied into each slot of a material texture
+// (-1 for none), and the material VarientMap pointing at the variant slots.
+func tilemapLayout(layout *TilemapLayout, varientMap VarientMap) (sources []int, varients VarientMap) {
+	sources = make([]int, 16, 16+varientCount(varientMap))
+	copy(sources, layout.Tiles[:])
 	for k, varient := range varientMap {
 		if len(varient) == 0 {
 			continue
 		}
 		// store the "default" tile as the first varient
-		m.VarientMap[k] = append(m.VarientMap[k], k)
+		varients[k] = append(varients[k], k)
 
 		for _, varientIndex := range varient {
-			m.VarientMap[k] = append(m.VarientMap[k], i)
+			varients[k] = append(varients[k], len(sources))
+			sources = append(sources, varientIndex)
+		}
+	}
+	return sources, varients
+}
 
-			x := (varientIndex % 4) * tileSize
-			y := (varientIndex / 4) * tileSize
+// varientCount returns the number of variant tiles in varientMap.
+func varientCount(varientMap VarientMap) int {
+	var n int
+	for _, v := range varientMap {
+		n += len(v)
+	}
+	return n
+}
 
-			opts.GeoM.Reset()
-			opts.GeoM.Translate(float64(i*tileSize), 0)
+// NewMaterialFromTilemapImage builds the same Material as NewMaterialFromTilemap from an
+// image.Image, on the CPU and without any ebiten call. The Material keeps its Pixels so the
+// SoftwareRenderer can draw it, its Texture is created on the first GPU render.
+func NewMaterialFromTilemapImage(tileSize int, tilemapImage image.Image, varientMap VarientMap) (Material, error) {
+	return NewMaterialFromTilemapImageLayout(tileSize, tilemapImage, LayoutJess, varientMap)
+}
 
-			m.Texture.DrawImage(tilemapImage.SubImage(image.Rect(x, y, x+tileSize, y+tileSize)).(*ebiten.Image), &opts)
+// NewMaterialFromTilemapImageLayout builds the same Material as NewMaterialFromTilemapLayout
+// on the CPU, see NewMaterialFromTilemapImage.
+func NewMaterialFromTilemapImageLayout(tileSize int, tilemapImage image.Image, layout TilemapLayout, varientMap VarientMap) (Material, error) {
+	sources, varients := tilemapLayout(&layout, varientMap)
+	if err := layout.check(tileSize, tilemapImage.Bounds(), sources, TilemapDimensionError); err != nil {
+		return Material{}, err
+	}
+
+	tilemap := toRGBA(tilemapImage)
+
+	m := Material{}
+	m.TileSize = tileSize
+	m.TileCount = len(sources)
+	m.Pixels = image.NewRGBA(image.Rect(0, 0, m.TileCount*tileSize, tileSize))
+	m.VarientMap = varients
 
-			i++
+	for slot, tile := range sources {
+		if tile < 0 {
+			continue
+		}
+		src := layout.tileRect(tile, tileSize).Min
+		if slot < 16 && layout.Orientations[slot] != OrientIdentity {
+			orientTile(m.Pixels, image.Pt(slot*tileSize, 0), tilemap, src, tileSize, layout.Orientations[slot])
+			continue
 		}
+		draw.Draw(m.Pixels, image.Rect(slot*tileSize, 0, (slot+1)*tileSize, tileSize), tilemap, src, draw.Src)
 	}
+	m.Opaque = slotOpacity(m.Pixels.Pix, m.Pixels.Stride, tileSize, m.TileCount)
 
 	return m, nil
 }
 
-// NewMaterialFromMask takes a base texture and a 4x4 mask and builds a Material.
-func NewMaterialFromMask(tileSize int, textureImage, maskImage *ebiten.Image, varientMap VarientMap) (Material, error) {
+// NewMaterialFromMaskImage builds the same Material as NewMaterialFromMask from image.Image
+// sources, on the CPU and without any ebiten call. See NewMaterialFromTilemapImage.
+func NewMaterialFromMaskImage(tileSize int, textureImage, maskImage image.Image, varientMap VarientMap) (Material, error) {
+	return NewMaterialFromMaskImageLayout(tileSize, textureImage, maskImage, LayoutJess, varientMap)
+}
+
+// NewMaterialFromMaskImageLayout builds the same Material as NewMaterialFromMaskLayout on the
+// CPU, see NewMaterialFromTilemapImage.
+func NewMaterialFromMaskImageLayout(tileSize int, textureImage, maskImage image.Image, layout TilemapLayout, varientMap VarientMap) (Material, error) {
+	return newMaskMaterialImage(tileSize, textureImage, maskImage, layout, varientMap, stamp)
+}
+
+// newMaskMaterialImage is newMaskMaterial on the CPU, stamp writing the texel of the texture t
+// stamped through the mask texel mk to d.
+func newMaskMaterialImage(tileSize int, textureImage, maskImage image.Image, layout TilemapLayout, varientMap VarientMap, stamp func(d, t, mk []byte)) (Material, error) {
 	if textureImage.Bounds().Dx() != tileSize || textureImage.Bounds().Dy() != tileSize {
 		return Material{}, TextureDimensionError
 	}
-	if maskImage.Bounds().Dx() != 4*tileSize || maskImage.Bounds().Dy() < 4*tileSize {
-		return Material{}, MaskDimensionError
-	}
-	if maskImage.Bounds().Dy() > 4*tileSize && maskImage.Bounds().Dy()%tileSize != 0 {
-		return Material{}, MaskDimensionError
-	}
-
-	multiplyOpts := &ebiten.DrawImageOptions{
-		Blend: ebiten.Blend{
-			BlendFactorSourceRGB:        ebiten.BlendFactorZero,
-			BlendFactorSourceAlpha:      ebiten.BlendFactorSourceAlpha,
-			BlendFactorDestinationRGB:   ebiten.BlendFactorSourceColor,
-			BlendFactorDestinationAlpha: ebiten.BlendFactorZero,
-			BlendOperationRGB:           ebiten.BlendOperationAdd,
-			BlendOperationAlpha:         ebiten.BlendOperationAdd,
-		},
+	sources, _ := tilemapLayout(&layout, varientMap)
+	if err := layout.check(tileSize, maskImage.Bounds(), sources, MaskDimensionError); err != nil {
+		return Material{}, err
 	}
 
-	maskTileHeight := maskImage.Bounds().Dy() / tileSize
+	texture := toRGBA(textureImage)
+	// orient the mask tiles first, the texture keeps its orientation
+	mask, layout := layout.flattenImage(toRGBA(maskImage), tileSize)
 
 	// grab the base material, multiply by the mask to "stamp out" the shape
-	tempImage := ebiten.NewImage(maskImage.Bounds().Dx(), maskImage.Bounds().Dy())
-	var stampOpts ebiten.DrawImageOptions
-	for i := range maskTileHeight * 4 {
-		x := (i % 4) * tileSize
-		y := (i / 4) * tileSize
-
-		stampOpts.GeoM.Reset()
-		stampOpts.GeoM.Translate(float64(x), float64(y))
-		tempImage.DrawImage(textureImage, &stampOpts)
+	stamped := image.NewRGBA(mask.Rect)
+	for y := range mask.Rect.Dy() {
+		for x := range mask.Rect.Dx() {
+			t := texture.Pix[texture.PixOffset(x%tileSize, y%tileSize):]
+			stamp(stamped.Pix[stamped.PixOffset(x, y):], t, mask.Pix[mask.PixOffset(x, y):])
+		}
 	}
-	tempImage.DrawImage(maskImage, multiplyOpts)
 
-	mat, err := NewMaterialFromTilemap(tileSize, tempImage, varientMap)
-	tempImage.Dispose()
-	return mat, err
+	return NewMaterialFromTilemapImageLayout(tileSize, stamped, layout, varientMap)
+}
+
+// toRGBA returns a premultiplied RGBA copy of img with its origin at (0, 0).
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// stamp writes the texture texel t multiplied by the mask texel mk to d, the same math as the
+// multiply blend of NewMaterialFromMask.
+func stamp(d, t, mk []byte) {
+	d[0] = mul8(t[0], mk[0])
+	d[1] = mul8(t[1], mk[1])
+	d[2] = mul8(t[2], mk[2])
+	d[3] = mul8(t[3], mk[3])
+}
+
+// mul8 multiplies two 0-255 values as normalized colors, rounding like the GPU.
+func mul8(a, b uint8) uint8 {
+	return uint8((uint32(a)*uint32(b) + 127) / 255)
 }
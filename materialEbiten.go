@@ -0,0 +1,161 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// MaterialEbiten holds the GPU resources of a Material, see Material for each field.
+// Headless builds (the dualgrid_headless build tag) leave it empty, materials then only
+// drawing from Pixels with the SoftwareRenderer.
+type MaterialEbiten struct {
+	Texture      *ebiten.Image
+	WorldTexture *ebiten.Image
+	Shader       *ebiten.Shader
+	Uniforms     map[string]any
+}
+
+// texture returns the material Texture, uploading Pixels first if needed.
+func (m *Material) texture() *ebiten.Image {
+	if m.Texture == nil && m.Pixels != nil {
+		m.Texture = ebiten.NewImageFromImage(m.Pixels)
+	}
+	return m.Texture
+}
+
+// hasShader reports whether the material is drawn with its own Shader.
+func (m *Material) hasShader() bool {
+	return m.Shader != nil
+}
+
+// readOpacity fills m.Opaque from the alpha of the GPU texture, when not known yet.
+// Ebiten only reads pixels back once the game runs: DualGrid calls it when building its atlas
+// on the first GPU render, materials built from image.Image sources get it from Pixels.
+func (m *Material) readOpacity() {
+	if m.Opaque != nil || m.Texture == nil || m.TileSize <= 0 {
+		return
+	}
+	b := m.Texture.Bounds()
+	pix := make([]byte, 4*b.Dx()*b.Dy())
+	m.Texture.ReadPixels(pix)
+	m.Opaque = frameOpacity(slotOpacity(pix, 4*b.Dx(), m.TileSize, m.TileCount*m.frameCount()), m.TileCount)
+
+	// A world texture shows through the mask with its own alpha
+	if m.WorldTexture != nil {
+		world := image.NewRGBA(m.WorldTexture.Bounds())
+		m.WorldTexture.ReadPixels(world.Pix)
+		if !opaqueImage(world) {
+			clear(m.Opaque)
+		}
+	}
+}
+
+// NewMaterialFromTilemap takes a 4x4 tilemap and builds a Material.
+// Tiles are laid out as LayoutJess, see NewMaterialFromTilemapLayout for other layouts.
+func NewMaterialFromTilemap(tileSize int, tilemapImage *ebiten.Image, varientMap VarientMap) (Material, error) {
+	return NewMaterialFromTilemapLayout(tileSize, tilemapImage, LayoutJess, varientMap)
+}
+
+// NewMaterialFromTilemapLayout takes a tilemap laid out as layout and builds a Material.
+// Rows below the layout hold variant tiles, indexed like the layout tiles.
+func NewMaterialFromTilemapLayout(tileSize int, tilemapImage *ebiten.Image, layout TilemapLayout, varientMap VarientMap) (Material, error) {
+	sources, varients := tilemapLayout(&layout, varientMap)
+	if err := layout.check(tileSize, tilemapImage.Bounds(), sources, TilemapDimensionError); err != nil {
+		return Material{}, err
+	}
+
+	m := Material{}
+	m.TileSize = tileSize
+	m.TileCount = len(sources)
+	m.Texture = ebiten.NewImage(m.TileCount*tileSize, tileSize)
+	m.VarientMap = varients
+
+	var opts ebiten.DrawImageOptions
+	origin := tilemapImage.Bounds().Min
+	for slot, tile := range sources {
+		if tile < 0 {
+			continue
+		}
+		r := layout.tileRect(tile, tileSize).Add(origin)
+		if slot < 16 && layout.Orientations[slot] != OrientIdentity {
+			drawOriented(m.Texture, image.Pt(slot*tileSize, 0), tilemapImage, r.Min, tileSize, layout.Orientations[slot])
+			continue
+		}
+
+		opts.GeoM.Reset()
+		opts.GeoM.Translate(float64(slot*tileSize), 0)
+		m.Texture.DrawImage(tilemapImage.SubImage(r).(*ebiten.Image), &opts)
+	}
+
+	return m, nil
+}
+
+// NewMaterialFromMask takes a base texture and a 4x4 mask and builds a Material.
+// Mask tiles are laid out as LayoutJess, see NewMaterialFromMaskLayout for other layouts.
+func NewMaterialFromMask(tileSize int, textureImage, maskImage *ebiten.Image, varientMap VarientMap) (Material, error) {
+	return NewMaterialFromMaskLayout(tileSize, textureImage, maskImage, LayoutJess, varientMap)
+}
+
+// NewMaterialFromMaskLayout takes a base texture and a mask laid out as layout and builds a Material.
+func NewMaterialFromMaskLayout(tileSize int, textureImage, maskImage *ebiten.Image, layout TilemapLayout, varientMap VarientMap) (Material, error) {
+	// premultiplied multiply, soft mask edges keep a valid premultiplied color
+	multiplyOpts := &ebiten.DrawImageOptions{
+		Blend: ebiten.Blend{
+			BlendFactorSourceRGB:        ebiten.BlendFactorZero,
+			BlendFactorSourceAlpha:      ebiten.BlendFactorZero,
+			BlendFactorDestinationRGB:   ebiten.BlendFactorSourceColor,
+			BlendFactorDestinationAlpha: ebiten.BlendFactorSourceAlpha,
+			BlendOperationRGB:           ebiten.BlendOperationAdd,
+			BlendOperationAlpha:         ebiten.BlendOperationAdd,
+		},
+	}
+	return newMaskMaterial(tileSize, textureImage, maskImage, layout, varientMap, func(tiled, mask *ebiten.Image) (*ebiten.Image, error) {
+		tiled.DrawImage(mask, multiplyOpts)
+		return tiled, nil
+	})
+}
+
+// newMaskMaterial builds a mask Material, stamp returning the texture tiled under every mask
+// tile once stamped through the mask. Either image may be the returned one.
+func newMaskMaterial(tileSize int, textureImage, maskImage *ebiten.Image, layout TilemapLayout, varientMap VarientMap, stamp func(tiled, mask *ebiten.Image) (*ebiten.Image, error)) (Material, error) {
+	if textureImage.Bounds().Dx() != tileSize || textureImage.Bounds().Dy() != tileSize {
+		return Material{}, TextureDimensionError
+	}
+	sources, _ := tilemapLayout(&layout, varientMap)
+	if err := layout.check(tileSize, maskImage.Bounds(), sources, MaskDimensionError); err != nil {
+		return Material{}, err
+	}
+
+	// orient the mask tiles first, the texture keeps its orientation
+	flatMask, layout := layout.flattenEbitenImage(maskImage, tileSize)
+	if flatMask != maskImage {
+		defer flatMask.Deallocate()
+	}
+	maskImage = flatMask
+
+	maskTileHeight := maskImage.Bounds().Dy() / tileSize
+
+	// grab the base material, multiply by the mask to "stamp out" the shape
+	tempImage := ebiten.NewImage(maskImage.Bounds().Dx(), maskImage.Bounds().Dy())
+	var stampOpts ebiten.DrawImageOptions
+	for i := range maskTileHeight * layout.Columns {
+		r := layout.tileRect(i, tileSize)
+
+		stampOpts.GeoM.Reset()
+		stampOpts.GeoM.Translate(float64(r.Min.X), float64(r.Min.Y))
+		tempImage.DrawImage(textureImage, &stampOpts)
+	}
+	defer tempImage.Dispose()
+	stamped, err := stamp(tempImage, maskImage)
+	if err != nil {
+		return Material{}, err
+	}
+	if stamped != tempImage && stamped != maskImage {
+		defer stamped.Dispose()
+	}
+
+	return NewMaterialFromTilemapLayout(tileSize, stamped, layout, varientMap)
+}
@@ -0,0 +1,38 @@
+package dualgrid
+
+// scaleTint returns cs as a Tint, its components are premultiplied like ColorScale.
+func scaleTint(cs *ColorScale) Tint {
+	return Tint{cs.R(), cs.G(), cs.B(), cs.A()}
+}
+
+// mul returns the component wise product of t and o.
+func (t Tint) mul(o Tint) Tint {
+	return Tint{t.R * o.R, t.G * o.G, t.B * o.B, t.A * o.A}
+}
+
+// materialScales fills dg.scales with the premultiplied color scale of every material,
+// global ColorScale included.
+func (dg *DualGrid) materialScales() []Tint {
+	global := scaleTint(&dg.Options.ColorScale)
+	dg.scales = dg.scales[:0]
+	for i := range dg.Materials {
+		dg.scales = append(dg.scales, global.mul(scaleTint(&dg.Materials[i].ColorScale)))
+	}
+	return dg.scales
+}
+
+// cullsBelow reports whether the opaque slots of m hide what is under them once drawn on the
+// tile (tileX, tileY) with the current options. A material Shader may output any alpha,
+// nothing is culled under it.
+func (dg *DualGrid) cullsBelow(tileX, tileY int, m *Material) bool {
+	if !dg.Options.sourceOver() || dg.Options.ColorScale.A() < 1 || m.ColorScale.A() < 1 || m.hasShader() {
+		return false
+	}
+	if dg.Tint != nil {
+		tl, tr, bl, br := dg.cornerTints(tileX, tileY)
+		if tl.A < 1 || tr.A < 1 || bl.A < 1 || br.A < 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// ColorScale is the premultiplied color scale of materials and RenderOptions.
+type ColorScale = ebiten.ColorScale
+
+// RenderOptions are the draw time options of a DualGrid. The zero value draws like
+// ebiten's defaults.
+//
+// The SoftwareRenderer applies ColorScale only, the ShaderRenderer ColorScale and Blend
+// (it reads texels directly, so Filter and Address do not apply). The same goes for the
+// VertexRenderer drawing materials with a Shader or a world texture: their shaders read the
+// atlas texel by texel, nearest and unbounded, whatever Filter and Address are.
+type RenderOptions struct {
+	// Filter used to sample the atlas, for materials drawn without a shader. FilterLinear can
+	// bleed neighbouring tiles in at non integer zoom levels.
+	Filter ebiten.Filter
+	// Blend of the tiles with img, BlendSourceOver when zero.
+	Blend ebiten.Blend
+	// ColorScale of every material, on top of Material.ColorScale. The ShaderRenderer clamps
+	// the combined scale to 0..1.
+	ColorScale ColorScale
+	// Address mode used to sample the atlas, for materials drawn without a shader.
+	Address ebiten.Address
+}
+
+// sourceOver reports whether tiles are blended source over, opaque tiles then hiding what is under them.
+func (o *RenderOptions) sourceOver() bool {
+	return o.Blend == (ebiten.Blend{}) || o.Blend == ebiten.BlendSourceOver
+}
@@ -0,0 +1,126 @@
+package dualgrid
+
+import "image"
+
+// Orientation is one of the 8 ways to rotate and flip a square tile. Oriented tiles reuse
+// the texture slot they were picked from, only the texture coordinates change.
+type Orientation uint8
+
+const (
+	OrientIdentity      Orientation = iota // as in the texture
+	OrientRotate90                         // clockwise
+	OrientRotate180                        // upside down
+	OrientRotate270                        // clockwise
+	OrientFlipX                            // mirrored left to right
+	OrientFlipY                            // mirrored top to bottom
+	OrientTranspose                        // mirrored across the top-left to bottom-right diagonal
+	OrientAntiTranspose                    // mirrored across the top-right to bottom-left diagonal
+)
+
+// Orientations is a set of Orientation, bit o allowing Orientation o. OrientIdentity is
+// always allowed.
+type Orientations uint8
+
+const (
+	FlipX           Orientations = 1<<OrientIdentity | 1<<OrientFlipX
+	FlipY           Orientations = 1<<OrientIdentity | 1<<OrientFlipY
+	Flips           Orientations = FlipX | FlipY | 1<<OrientRotate180
+	Rotations       Orientations = 1<<OrientIdentity | 1<<OrientRotate90 | 1<<OrientRotate180 | 1<<OrientRotate270
+	AllOrientations Orientations = 0xff
+)
+
+// orientation returns the Orientation of a tile allowing the given set, hashed like varients
+// with its own seed so orientations do not follow the varient picks.
+func (dg *DualGrid) orientation(tileX, tileY int, allowed Orientations) Orientation {
+	allowed |= 1 << OrientIdentity
+	if allowed == 1<<OrientIdentity {
+		return OrientIdentity
+	}
+	var n int
+	for o := range 8 {
+		n += int(allowed >> o & 1)
+	}
+	k := varientHash(tileX, tileY, (int(dg.Seed%varientPrime)+orientationSeed)%varientPrime) % n
+	for o := range 8 {
+		if allowed>>o&1 == 0 {
+			continue
+		}
+		if k == 0 {
+			return Orientation(o)
+		}
+		k--
+	}
+	return OrientIdentity
+}
+
+// keeping returns the orientations of s that keep the shape of bitmask, the others would draw
+// the tile of another bitmask.
+func (s Orientations) keeping(bitmask int) Orientations {
+	kept := Orientations(1 << OrientIdentity)
+	for o := range Orientation(8) {
+		if s>>o&1 != 0 && o.sourceBitmask(bitmask) == bitmask {
+			kept |= 1 << o
+		}
+	}
+	return kept
+}
+
+// orientationSeed offsets Seed for the orientation hash.
+const orientationSeed = 2039
+
+// source returns the texel of a size x size tile shown at (x, y) once oriented by o.
+func (o Orientation) source(x, y, size int) (sx, sy int) {
+	last := size - 1
+	switch o {
+	case OrientRotate90:
+		return y, last - x
+	case OrientRotate180:
+		return last - x, last - y
+	case OrientRotate270:
+		return last - y, x
+	case OrientFlipX:
+		return last - x, y
+	case OrientFlipY:
+		return x, last - y
+	case OrientTranspose:
+		return y, x
+	case OrientAntiTranspose:
+		return last - y, last - x
+	}
+	return x, y
+}
+
+// corners returns the unit square texture coordinates of the TL, TR, BL and BR quad vertices
+// once oriented by o.
+func (o Orientation) corners() [4][2]float32 {
+	var c [4][2]float32
+	for i, dst := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		sx, sy := o.source(dst[0], dst[1], 2)
+		c[i] = [2]float32{float32(sx), float32(sy)}
+	}
+	return c
+}
+
+// sourceBitmask returns the bitmask of the tile that shows as bitmask once oriented by o.
+func (o Orientation) sourceBitmask(bitmask int) int {
+	var src int
+	for corner := range 4 {
+		x, y := corner%2, corner/2 // TL, TR, BL, BR, bits 3 to 0
+		if bitmask>>(3-corner)&1 == 0 {
+			continue
+		}
+		sx, sy := o.source(x, y, 2)
+		src |= 1 << (3 - (sy*2 + sx))
+	}
+	return src
+}
+
+// orientTile copies the size x size tile at srcPt of src to dstPt of dst, oriented by o.
+func orientTile(dst *image.RGBA, dstPt image.Point, src *image.RGBA, srcPt image.Point, size int, o Orientation) {
+	for y := range size {
+		for x := range size {
+			sx, sy := o.source(x, y, size)
+			copy(dst.Pix[dst.PixOffset(dstPt.X+x, dstPt.Y+y):][:4], src.Pix[src.PixOffset(srcPt.X+sx, srcPt.Y+sy):])
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// drawOriented draws the size x size tile at srcPt of src to dstPt of dst, oriented by o.
+func drawOriented(dst *ebiten.Image, dstPt image.Point, src *ebiten.Image, srcPt image.Point, size int, o Orientation) {
+	uv := o.corners()
+	s := float32(size)
+	var verts [4]ebiten.Vertex
+	for i := range verts {
+		verts[i] = ebiten.Vertex{
+			DstX: float32(dstPt.X) + float32(i%2)*s, DstY: float32(dstPt.Y) + float32(i/2)*s,
+			SrcX: float32(srcPt.X) + uv[i][0]*s, SrcY: float32(srcPt.Y) + uv[i][1]*s,
+			ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1,
+		}
+	}
+	dst.DrawTriangles(verts[:], []uint16{0, 1, 2, 1, 3, 2}, src, nil)
+}
@@ -0,0 +1,61 @@
+package dualgrid
+
+import (
+	"image"
+	"testing"
+)
+
+func TestOrientation(t *testing.T) {
+	const size = 4
+	src := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := range src.Pix {
+		src.Pix[i] = byte(i)
+	}
+
+	seen := map[string]Orientation{}
+	for o := range Orientation(8) {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		orientTile(dst, image.Point{}, src, image.Point{}, size, o)
+		if prev, ok := seen[string(dst.Pix)]; ok {
+			t.Errorf("orientations %d and %d give the same tile", prev, o)
+		}
+		seen[string(dst.Pix)] = o
+
+		// Vertex texture coords land on the same texels as the CPU copy
+		for i, c := range o.corners() {
+			x, y := i%2*(size-1), i/2*(size-1)
+			sx, sy := int(c[0])*(size-1), int(c[1])*(size-1)
+			if got, want := dst.RGBAAt(x, y), src.RGBAAt(sx, sy); got != want {
+				t.Errorf("orientation %d corner %d shows %v, texture coords point at %v", o, i, got, want)
+			}
+		}
+	}
+}
+
+func TestResolveOrientation(t *testing.T) {
+	dg := testGrid(16, 16, 1, 0)
+	dg.Materials[0].Orientations[0b1111] = FlipX
+
+	var counts [8]int
+	dg.ResolveRect(0, 0, 17, 17, func(tileX, tileY int, layers []Layer) {
+		counts[layers[0].Orientation]++
+	})
+	if counts[OrientIdentity] == 0 || counts[OrientFlipX] == 0 || counts[OrientIdentity]+counts[OrientFlipX] != 17*17 {
+		t.Errorf("with FlipX, orientation counts = %v, want only identity and FlipX", counts)
+	}
+}
+
+func TestResolveOrientationKeepsShape(t *testing.T) {
+	dg := testGrid(16, 16, 2, 0)
+	dg.WorldGrid.FillRect(0, 0, 16, 8, 1)
+	dg.Materials[1].Orientations[0b1100] = AllOrientations
+
+	// Only the identity and the left to right flip keep the top edge a top edge
+	var counts [8]int
+	dg.ResolveRect(0, 8, 17, 1, func(tileX, tileY int, layers []Layer) {
+		counts[layers[1].Orientation]++
+	})
+	if counts[OrientIdentity] == 0 || counts[OrientFlipX] == 0 || counts[OrientIdentity]+counts[OrientFlipX] != 17 {
+		t.Errorf("top edges allowing every orientation, orientation counts = %v, want only identity and FlipX", counts)
+	}
+}
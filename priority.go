@@ -0,0 +1,42 @@
+package dualgrid
+
+import "slices"
+
+// priorities returns the render rank of every TileType (a higher rank draws on top and wins
+// corner comparisons) and the materials sorted bottom to top.
+//
+// Materials listed in DualGrid.Priority come first in that order, the others are placed
+// below them by Material.Z then by index. TileTypes without a material keep their index as
+// rank, above every material.
+func (dg *DualGrid) priorities() (rank [256]int, order []TileType) {
+	numMats := len(dg.Materials)
+	order = make([]TileType, 0, numMats)
+	var listed [256]bool
+	for _, t := range dg.Priority {
+		if int(t) < numMats && !listed[t] {
+			listed[t] = true
+		}
+	}
+	for i := range numMats {
+		if !listed[i] {
+			order = append(order, TileType(i))
+		}
+	}
+	slices.SortStableFunc(order, func(a, b TileType) int {
+		return dg.Materials[a].Z - dg.Materials[b].Z
+	})
+	for _, t := range dg.Priority {
+		if listed[t] {
+			order = append(order, t)
+			listed[t] = false // ignore duplicates
+		}
+	}
+
+	for i := range rank {
+		rank[i] = i
+	}
+	for r, t := range order {
+		rank[t] = r
+	}
+	return rank, order
+}
@@ -0,0 +1,21 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// Renderer draws a DualGrid into img, img's top-left pixel being the world pixel (left, top).
+// Renderers draw over img without clearing it.
+//
+// Set DualGrid.Renderer to pick one: VertexRenderer (default), ShaderRenderer or SoftwareRenderer.
+type Renderer interface {
+	Render(dg *DualGrid, img *ebiten.Image, left, top int)
+}
+
+// VertexRenderer is the default Renderer. It builds one quad per visible tile layer
+// on the CPU and draws them from the material atlas.
+type VertexRenderer struct{}
+
+func (VertexRenderer) Render(dg *DualGrid, img *ebiten.Image, left, top int) {
+	dg.renderTo(img, left, top)
+}
@@ -0,0 +1,258 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// testGame runs the tests from its first Update, ebiten only reading pixels back once the
+// game runs.
+type testGame struct {
+	m    *testing.M
+	code int
+}
+
+func (g *testGame) Update() error {
+	g.code = g.m.Run()
+	return ebiten.Termination
+}
+
+func (*testGame) Draw(*ebiten.Image) {}
+
+func (*testGame) Layout(int, int) (int, int) { return 320, 240 }
+
+func TestMain(m *testing.M) {
+	g := &testGame{m: m, code: 1}
+	if err := ebiten.RunGame(g); err != nil {
+		panic(err)
+	}
+	os.Exit(g.code)
+}
+
+// readPixels returns the pixels of img read back from the GPU, skipping the test in short mode.
+func readPixels(t *testing.T, img *ebiten.Image) *image.RGBA {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("reads GPU pixels back")
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	img.ReadPixels(rgba.Pix)
+	return rgba
+}
+
+// checkPixels compares got to want, allowing the GPU one unit of rounding per channel.
+func checkPixels(t *testing.T, got, want *image.RGBA) {
+	t.Helper()
+	var mismatches int
+	for i := range got.Pix {
+		if d := int(got.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+			if mismatches == 0 {
+				p := i / 4
+				t.Errorf("pixel (%d, %d) = %v, want %v", p%got.Rect.Dx(), p/got.Rect.Dx(), got.Pix[i&^3:i&^3+4], want.Pix[i&^3:i&^3+4])
+			}
+			mismatches++
+		}
+	}
+	if mismatches > 0 {
+		t.Errorf("%d channels differ by more than 1", mismatches)
+	}
+}
+
+func TestVertexRendererMatchesSoftware(t *testing.T) {
+	for _, tt := range goldenTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dg := tt.setup(t)
+			w := (dg.WorldGrid.Width + 1) * dg.TileSize
+			h := (dg.WorldGrid.Height + 1) * dg.TileSize
+			img := ebiten.NewImage(w, h)
+			dg.DrawTo(img, tt.left, tt.top)
+			got := readPixels(t, img)
+
+			want := image.NewRGBA(got.Rect)
+			dg.DrawToRGBA(want, tt.left, tt.top)
+			checkPixels(t, got, want)
+		})
+	}
+}
+
+// testShaderSrc is a material shader drawing the plain texture.
+const testShaderSrc = `//kage:unit pixels
+
+package main
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4, custom vec4) vec4 {
+	return imageSrc0At(srcPos) * color
+}
+`
+
+func TestVertexRendererMaterialShader(t *testing.T) {
+	dg := testGrid(4, 4, 3, 0)
+	dg.WorldGrid.FillRect(1, 1, 2, 2, 1)
+	dg.SetCell(3, 3, 2)
+	shader, err := ebiten.NewShader([]byte(testShaderSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.Materials[1].Shader = shader
+
+	img := ebiten.NewImage(5*testTileSize, 5*testTileSize)
+	dg.DrawTo(img, -3, 2)
+
+	// Material 1 splits the batch in three draw calls
+	s := dg.Stats()
+	if s.DrawCalls != 3 {
+		t.Errorf("DrawCalls = %d, want 3", s.DrawCalls)
+	}
+
+	// Every layer of the view tiles is drawn, shader or not
+	want := make([]int, len(dg.Materials))
+	dg.ResolveRect(-1, 0, 6, 6, func(_, _ int, layers []Layer) {
+		for _, l := range layers {
+			want[l.Material]++
+		}
+	})
+	if !slices.Equal(s.Quads, want) {
+		t.Errorf("Quads = %v, want %v", s.Quads, want)
+	}
+}
+
+func TestResolveShaderCulling(t *testing.T) {
+	dg := testGrid(2, 2, 2, 0)
+	dg.WorldGrid.FillRect(0, 0, 2, 2, 1)
+	dg.SetCell(0, 0, 0)
+	dg.Materials[1].Opaque = make([]bool, 16)
+	dg.Materials[1].Opaque[0b0111] = true
+	shader, err := ebiten.NewShader([]byte(testShaderSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.Materials[1].Shader = shader
+
+	// The shader may draw the opaque slot translucent, material 0 is kept under it
+	want := []Layer{{Material: 0, Bitmask: 0b1111, Slot: 0b1111}, {Material: 1, Bitmask: 0b0111, Slot: 0b0111}}
+	if got := dg.Resolve(1, 1); !slices.Equal(got, want) {
+		t.Errorf("Resolve(1, 1) = %v, want %v", got, want)
+	}
+}
+
+func TestViewCanvasF(t *testing.T) {
+	dg := testGrid(10, 10, 1, 0)
+	left, top, viewW, viewH := 17.25, 30.5, 70.5, 50.0
+	canvas := dg.ViewCanvasF(viewW, viewH, left, top)
+	if w, h := viewCanvasSize(viewW, viewH, testTileSize); canvas.Bounds().Dx() != w || canvas.Bounds().Dy() != h {
+		t.Fatalf("canvas size = %v, want %dx%d", canvas.Bounds().Size(), w, h)
+	}
+
+	// Drawn at TileOffset, tile corners land on their world position and the quads cover the view
+	x, y := dg.TileOffset(left, top)
+	minX, minY, maxX, maxY := float32(1e9), float32(1e9), float32(-1e9), float32(-1e9)
+	for _, v := range dg.batch {
+		wx, wy := v.DstX+float32(x), v.DstY+float32(y)
+		if wx != v.Custom0 || wy != v.Custom1 {
+			t.Fatalf("vertex at world (%v, %v) drawn at world (%v, %v)", v.Custom0, v.Custom1, wx, wy)
+		}
+		minX, minY, maxX, maxY = min(minX, wx), min(minY, wy), max(maxX, wx), max(maxY, wy)
+	}
+	if minX > float32(left) || minY > float32(top) || maxX < float32(left+viewW) || maxY < float32(top+viewH) {
+		t.Errorf("quads cover (%v, %v)-(%v, %v), want (%v, %v)-(%v, %v)", minX, minY, maxX, maxY, left, top, left+viewW, top+viewH)
+	}
+}
+
+func TestVertexRendererStats(t *testing.T) {
+	dg := testGrid(4, 4, 3, 0)
+	dg.WorldGrid.FillRect(1, 1, 2, 2, 1)
+	img := ebiten.NewImage(5*testTileSize, 5*testTileSize)
+
+	dg.DrawTo(img, 0, 0)
+	if s := dg.Stats(); s.VerticesReused != 0 || s.VerticesAllocated == 0 {
+		t.Errorf("first render reused %d and allocated %d vertices", s.VerticesReused, s.VerticesAllocated)
+	}
+
+	// Every vertex goes once in a stripe buffer and once in the batch, all in reused capacity
+	dg.DrawTo(img, 0, 0)
+	if s := dg.Stats(); s.VerticesReused != 8*s.TotalQuads() || s.VerticesAllocated != 0 {
+		t.Errorf("second render reused %d and allocated %d vertices for %d quads", s.VerticesReused, s.VerticesAllocated, s.TotalQuads())
+	}
+}
+
+func TestVertexRendererReadsOpacity(t *testing.T) {
+	dg := testGrid(2, 2, 1, 0)
+	m, err := NewMaterialFromTilemap(testTileSize, ebiten.NewImage(4*testTileSize, 4*testTileSize), VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.AddMaterial(m)
+
+	// Ebiten textures are only read back by the first render
+	if dg.Materials[1].Opaque != nil {
+		t.Fatal("opacity known before the first render")
+	}
+	dg.DrawTo(ebiten.NewImage(testTileSize, testTileSize), 0, 0)
+	if len(dg.Materials[1].Opaque) != 16 {
+		t.Errorf("Opaque = %v after the first render, want 16 slots", dg.Materials[1].Opaque)
+	}
+}
+
+func TestMarkDirtyRebuildsAtlas(t *testing.T) {
+	dg := testGrid(2, 2, 2, 0)
+	img := ebiten.NewImage(testTileSize, testTileSize)
+	dg.DrawTo(img, 0, 0)
+	atlas := dg.atlas
+
+	// Materials replaced in place go unnoticed until MarkDirty
+	dg.Materials[1] = Material{TileSize: testTileSize, TileCount: 16}
+	dg.Materials[1].Texture = ebiten.NewImage(16*testTileSize, testTileSize)
+	dg.DrawTo(img, 0, 0)
+	if dg.atlas != atlas {
+		t.Fatal("atlas rebuilt without MarkDirty")
+	}
+	dg.MarkDirty()
+	dg.DrawTo(img, 0, 0)
+	if dg.atlas == atlas {
+		t.Error("atlas not rebuilt after MarkDirty")
+	}
+}
+
+func TestShaderRendererUploadsChangedCells(t *testing.T) {
+	dg := testGrid(8, 6, 2, 0)
+	sr, err := NewShaderRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.Renderer = sr
+	img := ebiten.NewImage(4*testTileSize, 4*testTileSize)
+	uploaded := func() int { return len(sr.pix) / 8 } // texels, cells and tints
+
+	dg.DrawTo(img, 0, 0)
+	if got, want := uploaded(), 10*8; got != want {
+		t.Errorf("first render uploaded %d texels, want the whole grid %d", got, want)
+	}
+
+	// A cell only uploads the texels around it, the shared edge tints included
+	dg.SetCell(3, 2, 1)
+	dg.DrawTo(img, 0, 0)
+	if got := uploaded(); got != 9 {
+		t.Errorf("one cell changed, uploaded %d texels, want 9", got)
+	}
+
+	// Nothing changed, nothing uploaded
+	sr.pix = sr.pix[:0]
+	dg.DrawTo(img, 0, 0)
+	if got := uploaded(); got != 0 {
+		t.Errorf("nothing changed, uploaded %d texels", got)
+	}
+
+	// Direct writes go unnoticed until MarkDirty
+	dg.WorldGrid.Cells[0] = 1
+	dg.MarkDirty()
+	dg.DrawTo(img, 0, 0)
+	if got, want := uploaded(), 10*8; got != want {
+		t.Errorf("after MarkDirty, uploaded %d texels, want the whole grid %d", got, want)
+	}
+}
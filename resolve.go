@@ -0,0 +1,149 @@
+package dualgrid
+
+// Layer is one material drawn on a dual-grid tile.
+type Layer struct {
+	Material TileType
+	Bitmask  int // 4bit number 0b0000 Top-Left, Top-Right, Bottom-Left and Bottom-Right
+	Slot     int // Material texture slot, Bitmask unless a varient was picked
+	// Transition is 1 + the index in DualGrid.Transitions of the material drawing this
+	// layer, 0 when the layer uses its own Material texture.
+	Transition int
+	// Orientation the slot is drawn with, see Material.Orientations
+	Orientation Orientation
+}
+
+// Resolve returns the layers drawn on the dual-grid tile (tileX, tileY), bottom to top
+// (see DualGrid.Priority),
+// leaving out layers hidden under a fully opaque one and Hidden materials.
+// Dual-grid tiles range over 0..Width and 0..Height, tiles outside return nil.
+//
+// Resolve is pure Go and does not need a running game. Materials built from ebiten images
+// only cull the layers below them after the first GPU render, see Material.Opaque.
+func (dg *DualGrid) Resolve(tileX, tileY int) []Layer {
+	if tileX < 0 || tileY < 0 || tileX > dg.WorldGrid.Width || tileY > dg.WorldGrid.Height {
+		return nil
+	}
+	rank, _ := dg.priorities()
+	var layers [4]Layer
+	n := dg.resolve(tileX, tileY, &rank, &layers)
+	return append([]Layer(nil), layers[:n]...)
+}
+
+// ResolveRect calls fn with the layers of every dual-grid tile inside the grid and the
+// tileW x tileH rect at (tileX, tileY), column by column. layers is only valid during the call.
+//
+// This is what the renderer draws.
+func (dg *DualGrid) ResolveRect(tileX, tileY, tileW, tileH int, fn func(tileX, tileY int, layers []Layer)) {
+	x0, x1 := max(tileX, 0), min(tileX+tileW, dg.WorldGrid.Width+1)
+	y0, y1 := max(tileY, 0), min(tileY+tileH, dg.WorldGrid.Height+1)
+
+	rank, _ := dg.priorities()
+	var layers [4]Layer
+	for x := x0; x < x1; x++ {
+		for y := y0; y < y1; y++ {
+			n := dg.resolve(x, y, &rank, &layers)
+			fn(x, y, layers[:n])
+		}
+	}
+}
+
+// Corners returns the materials at the four corners of the dual-grid tile (tileX, tileY).
+// Corners outside the grid are DefaultMaterial.
+func (dg *DualGrid) Corners(tileX, tileY int) (tl, tr, bl, br TileType) {
+	gridW, gridH := dg.WorldGrid.Width, dg.WorldGrid.Height
+	cells := dg.WorldGrid.Cells
+
+	tl = dg.DefaultMaterial
+	tr = dg.DefaultMaterial
+	bl = dg.DefaultMaterial
+	br = dg.DefaultMaterial
+
+	// If inbound set corners to grid value
+	if tileX >= 1 && tileY >= 1 && tileX <= gridW && tileY <= gridH {
+		tl = cells[(tileX-1)*gridH+(tileY-1)]
+	}
+	if tileX >= 0 && tileY >= 1 && tileX < gridW && tileY <= gridH {
+		tr = cells[tileX*gridH+(tileY-1)]
+	}
+	if tileX >= 1 && tileY >= 0 && tileX <= gridW && tileY < gridH {
+		bl = cells[(tileX-1)*gridH+tileY]
+	}
+	if tileX >= 0 && tileY >= 0 && tileX < gridW && tileY < gridH {
+		br = cells[tileX*gridH+tileY]
+	}
+	return tl, tr, bl, br
+}
+
+// resolve fills layers for the tile (tileX, tileY) and returns how many were used.
+// rank comes from priorities.
+func (dg *DualGrid) resolve(tileX, tileY int, rank *[256]int, layers *[4]Layer) int {
+	tl, tr, bl, br := dg.Corners(tileX, tileY)
+
+	// Distinct corner materials, lowest rank first (at most 4 meet at a tile)
+	var mats [4]TileType
+	var count int
+	for _, c := range [4]TileType{tl, tr, bl, br} {
+		if int(c) >= len(dg.Materials) {
+			continue
+		}
+		i := count
+		for i > 0 && rank[mats[i-1]] >= rank[c] {
+			i--
+		}
+		if i < count && mats[i] == c {
+			continue
+		}
+		copy(mats[i+1:count+1], mats[i:count])
+		mats[i] = c
+		count++
+	}
+
+	var n int
+	for l, matType := range mats[:count] {
+		if dg.Materials[matType].Hidden {
+			continue // still shapes the edges of the others
+		}
+		r := rank[matType]
+		bitmask := 0b0000
+		if rank[tl] >= r || dg.connects(matType, tl) {
+			bitmask |= 1 << 3
+		}
+		if rank[tr] >= r || dg.connects(matType, tr) {
+			bitmask |= 1 << 2
+		}
+		if rank[bl] >= r || dg.connects(matType, bl) {
+			bitmask |= 1 << 1
+		}
+		if rank[br] >= r || dg.connects(matType, br) {
+			bitmask |= 1 << 0
+		}
+
+		// Edges drawn over a registered lower material use the transition tiles
+		mat := &dg.Materials[matType]
+		var transition int
+		if l > 0 && bitmask != 0b1111 && len(dg.Transitions) > 0 {
+			if t := dg.transitionIndex(matType, mats[l-1]); t >= 0 {
+				mat = &dg.Transitions[t].Material
+				transition = t + 1
+			}
+		}
+
+		// pick a varient deterministically from the world-space coords
+		slot := bitmask
+		if v := mat.VarientMap[bitmask]; len(v) > 0 {
+			slot = v[dg.varientIndex(tileX, tileY, matType, mat, bitmask)]
+		}
+		orientation := dg.orientation(tileX, tileY, mat.Orientations[bitmask].keeping(bitmask))
+		layers[n] = Layer{Material: matType, Bitmask: bitmask, Slot: slot, Transition: transition, Orientation: orientation}
+		n++
+	}
+
+	// Skip every layer hidden under the topmost fully opaque one
+	for l := n - 1; l > 0; l-- {
+		if dg.layerMaterial(layers[l]).isOpaque(layers[l].Slot) && dg.cullsBelow(tileX, tileY, &dg.Materials[layers[l].Material]) {
+			copy(layers[:], layers[l:n])
+			return n - l
+		}
+	}
+	return n
+}
@@ -0,0 +1,196 @@
+package dualgrid
+
+import (
+	"slices"
+	"testing"
+)
+
+// testGrid returns a grid with n materials that have no texture, enough to resolve tiles.
+func testGrid(w, h, n int, def TileType) DualGrid {
+	dg := NewDualGrid(w, h, testTileSize, def)
+	for range n {
+		dg.AddMaterial(Material{TileSize: testTileSize, TileCount: 16})
+	}
+	return dg
+}
+
+func TestResolve(t *testing.T) {
+	dg := testGrid(2, 2, 3, 0)
+	dg.SetCell(0, 0, 2)
+	dg.SetCell(1, 0, 1)
+
+	// Tile (1, 1) has the corners tl=2 tr=1 bl=0 br=0
+	want := []Layer{
+		{Material: 0, Bitmask: 0b1111, Slot: 0b1111},
+		{Material: 1, Bitmask: 0b1100, Slot: 0b1100},
+		{Material: 2, Bitmask: 0b1000, Slot: 0b1000},
+	}
+	if got := dg.Resolve(1, 1); !slices.Equal(got, want) {
+		t.Errorf("Resolve(1, 1) = %v, want %v", got, want)
+	}
+	if got := dg.Resolve(3, 0); got != nil {
+		t.Errorf("Resolve outside the grid = %v, want nil", got)
+	}
+}
+
+func TestResolvePriority(t *testing.T) {
+	dg := testGrid(2, 2, 3, 0)
+	dg.SetCell(0, 0, 2)
+	dg.SetCell(1, 0, 1)
+
+	want := []Layer{
+		{Material: 2, Bitmask: 0b1111, Slot: 0b1111},
+		{Material: 1, Bitmask: 0b0111, Slot: 0b0111},
+		{Material: 0, Bitmask: 0b0011, Slot: 0b0011},
+	}
+
+	dg.Priority = []TileType{2, 1, 0}
+	if got := dg.Resolve(1, 1); !slices.Equal(got, want) {
+		t.Errorf("with Priority, Resolve(1, 1) = %v, want %v", got, want)
+	}
+
+	dg.Priority = nil
+	dg.Materials[0].Z = 2
+	dg.Materials[1].Z = 1
+	if got := dg.Resolve(1, 1); !slices.Equal(got, want) {
+		t.Errorf("with Z, Resolve(1, 1) = %v, want %v", got, want)
+	}
+}
+
+func TestResolveOpaqueCulling(t *testing.T) {
+	dg := testGrid(2, 2, 2, 0)
+	dg.WorldGrid.FillRect(0, 0, 2, 2, 1)
+	dg.Materials[1].Opaque = make([]bool, 16)
+	dg.Materials[1].Opaque[0b1111] = true
+
+	// Tile (1, 1) is fully covered by material 1, tile (0, 0) only on its bottom right
+	if got, want := dg.Resolve(1, 1), []Layer{{Material: 1, Bitmask: 0b1111, Slot: 0b1111}}; !slices.Equal(got, want) {
+		t.Errorf("Resolve(1, 1) = %v, want %v", got, want)
+	}
+	if got := dg.Resolve(0, 0); len(got) != 2 {
+		t.Errorf("Resolve(0, 0) = %v, want 2 layers", got)
+	}
+}
+
+func TestResolveOptions(t *testing.T) {
+	dg := testGrid(2, 2, 2, 0)
+	dg.WorldGrid.FillRect(0, 0, 2, 2, 1)
+	dg.SetCell(0, 0, 0)
+	dg.Materials[1].Opaque = make([]bool, 16)
+	dg.Materials[1].Opaque[0b0111] = true
+
+	// Tile (1, 1) is material 0 on its top left corner only, culled by default
+	full := []Layer{{Material: 0, Bitmask: 0b1111, Slot: 0b1111}, {Material: 1, Bitmask: 0b0111, Slot: 0b0111}}
+	if got := dg.Resolve(1, 1); !slices.Equal(got, full[1:]) {
+		t.Errorf("Resolve(1, 1) = %v, want %v", got, full[1:])
+	}
+
+	// A translucent color scale shows what is under opaque slots
+	dg.Materials[1].ColorScale.ScaleAlpha(0.5)
+	if got := dg.Resolve(1, 1); !slices.Equal(got, full) {
+		t.Errorf("with a translucent material, Resolve(1, 1) = %v, want %v", got, full)
+	}
+	dg.Materials[1].ColorScale.Reset()
+	dg.Options.ColorScale.ScaleAlpha(0.5)
+	if got := dg.Resolve(1, 1); !slices.Equal(got, full) {
+		t.Errorf("with a translucent grid, Resolve(1, 1) = %v, want %v", got, full)
+	}
+	dg.Options.ColorScale.Reset()
+
+	// Hidden materials are skipped and cull nothing
+	dg.Materials[1].Hidden = true
+	if got, want := dg.Resolve(1, 1), full[:1]; !slices.Equal(got, want) {
+		t.Errorf("with a hidden material, Resolve(1, 1) = %v, want %v", got, want)
+	}
+}
+
+func TestResolveTransition(t *testing.T) {
+	dg := testGrid(2, 2, 3, 0)
+	dg.SetCell(0, 0, 2)
+	dg.SetCell(1, 0, 1)
+	dg.AddTransition(2, 1, Material{TileSize: testTileSize, TileCount: 16})
+	dg.AddTransition(1, 2, Material{TileSize: testTileSize, TileCount: 16})
+
+	// Material 2 is drawn over 1 on tile (1, 1), 1 over 0 has no transition
+	want := []Layer{
+		{Material: 0, Bitmask: 0b1111, Slot: 0b1111},
+		{Material: 1, Bitmask: 0b1100, Slot: 0b1100},
+		{Material: 2, Bitmask: 0b1000, Slot: 0b1000, Transition: 1},
+	}
+	if got := dg.Resolve(1, 1); !slices.Equal(got, want) {
+		t.Errorf("Resolve(1, 1) = %v, want %v", got, want)
+	}
+
+	// Tile (0, 1) has 2 directly over 0
+	if got := dg.Resolve(0, 1); got[len(got)-1].Transition != 0 {
+		t.Errorf("Resolve(0, 1) = %v, want no transition", got)
+	}
+}
+
+func TestResolveConnects(t *testing.T) {
+	dg := testGrid(2, 2, 3, 0)
+	dg.SetCell(0, 0, 2)
+	dg.SetCell(1, 0, 1)
+
+	// Materials 1 and 2 are one terrain, 2 covers the corner of 1 on tile (1, 1)
+	want := []Layer{
+		{Material: 0, Bitmask: 0b1111, Slot: 0b1111},
+		{Material: 1, Bitmask: 0b1100, Slot: 0b1100},
+		{Material: 2, Bitmask: 0b1100, Slot: 0b1100},
+	}
+	dg.Materials[1].Terrain = 1
+	dg.Materials[2].Terrain = 1
+	if got := dg.Resolve(1, 1); !slices.Equal(got, want) {
+		t.Errorf("with Terrain, Resolve(1, 1) = %v, want %v", got, want)
+	}
+
+	// Connects overrides Terrain
+	want[2] = Layer{Material: 2, Bitmask: 0b1011, Slot: 0b1011}
+	dg.Connects = func(m, corner TileType) bool { return m == 2 && corner == 0 }
+	if got := dg.Resolve(1, 1); !slices.Equal(got, want) {
+		t.Errorf("with Connects, Resolve(1, 1) = %v, want %v", got, want)
+	}
+}
+
+func TestResolveVarients(t *testing.T) {
+	dg := testGrid(16, 16, 1, 0)
+	dg.Materials[0].VarientMap[0b1111] = []int{15, 16, 17}
+	slots := func() []int {
+		var s []int
+		dg.ResolveRect(0, 0, 17, 17, func(tileX, tileY int, layers []Layer) {
+			s = append(s, layers[0].Slot)
+		})
+		return s
+	}
+
+	// Every strategy is deterministic and uses each varient
+	for _, strategy := range []VarientStrategy{VarientHash, VarientClustered} {
+		dg.VarientStrategy = strategy
+		got := slots()
+		if !slices.Equal(got, slots()) {
+			t.Errorf("strategy %d is not deterministic", strategy)
+		}
+		for _, slot := range []int{15, 16, 17} {
+			if !slices.Contains(got, slot) {
+				t.Errorf("strategy %d never picks slot %d", strategy, slot)
+			}
+		}
+		dg.Seed = 7
+		if slices.Equal(got, slots()) {
+			t.Errorf("strategy %d ignores Seed", strategy)
+		}
+		dg.Seed = 0
+	}
+
+	// A zero weight is never picked
+	dg.Materials[0].VarientWeights[0b1111] = []int{1, 0, 3}
+	if slices.Contains(slots(), 16) {
+		t.Errorf("slot 16 picked with a zero weight")
+	}
+
+	// PickVarient overrides the strategy
+	dg.PickVarient = func(tileX, tileY int, m TileType, bitmask, count int) int { return tileX }
+	if got := dg.Resolve(4, 0); got[0].Slot != 16 {
+		t.Errorf("with PickVarient, Resolve(4, 0) = %v, want slot 16", got)
+	}
+}
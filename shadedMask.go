@@ -0,0 +1,52 @@
+package dualgrid
+
+import (
+	"image"
+	"image/color"
+)
+
+// MaskShading holds the colors a shaded mask blends the texture toward, see
+// NewMaterialFromShadedMask.
+//
+//	Highlight:
+//		Color blended in by the mask green channel, white when nil.
+//
+//	Shadow:
+//		Color blended in by the mask blue channel, black when nil.
+type MaskShading struct {
+	Highlight color.Color
+	Shadow    color.Color
+}
+
+// colors returns the highlight and shadow colors, premultiplied in 0..1 units.
+func (s *MaskShading) colors() (highlight, shadow [4]float32) {
+	normalize := func(c color.Color, def color.Color) [4]float32 {
+		if c == nil {
+			c = def
+		}
+		r, g, b, a := c.RGBA()
+		return [4]float32{float32(r) / 0xffff, float32(g) / 0xffff, float32(b) / 0xffff, float32(a) / 0xffff}
+	}
+	return normalize(s.Highlight, color.White), normalize(s.Shadow, color.Black)
+}
+
+// NewMaterialFromShadedMaskImage builds the same Material as NewMaterialFromShadedMask from
+// image.Image sources, on the CPU and without any ebiten call. See NewMaterialFromTilemapImage.
+func NewMaterialFromShadedMaskImage(tileSize int, textureImage, maskImage image.Image, layout TilemapLayout, shading MaskShading, varientMap VarientMap) (Material, error) {
+	highlight, shadow := shading.colors()
+	return newMaskMaterialImage(tileSize, textureImage, maskImage, layout, varientMap, func(d, t, mk []byte) {
+		stampShaded(d, t, mk, highlight, shadow)
+	})
+}
+
+// stampShaded writes the texture texel t shaded and covered by the shaded mask texel mk to d,
+// the same math as the shadedMask shader.
+func stampShaded(d, t, mk []byte, highlight, shadow [4]float32) {
+	coverage, h, s := float32(mk[0])/255, float32(mk[1])/255, float32(mk[2])/255
+	for i := range 4 {
+		c := float32(t[i]) / 255
+		c += (highlight[i] - c) * h
+		c += (shadow[i] - c) * s
+		d[i] = uint8(min(max(c*coverage*255+0.5, 0), 255))
+	}
+}
@@ -0,0 +1,49 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	_ "embed"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+//go:embed shadedMask.kage
+var shadedMaskShaderSrc []byte
+
+// Compiled on the first NewMaterialFromShadedMask call
+var shadedMaskShader *ebiten.Shader
+
+// NewMaterialFromShadedMask takes a base texture and a shaded mask laid out as layout and builds
+// a Material with soft, beveled or outlined edges from a single texture. Each mask texel is read
+// as channels instead of a cut out:
+//
+//	red:   coverage, 0 transparent to 255 fully covered, in between for soft edges
+//	green: amount of MaskShading.Highlight blended over the texture (bevel lights, outlines)
+//	blue:  amount of MaskShading.Shadow blended over the texture (bevel shadows, drop shadows)
+//
+// A red on black mask is the cut out of NewMaterialFromMask. Oriented mask tiles (see
+// TilemapLayout.Orientations) turn their shading with them.
+func NewMaterialFromShadedMask(tileSize int, textureImage, maskImage *ebiten.Image, layout TilemapLayout, shading MaskShading, varientMap VarientMap) (Material, error) {
+	if shadedMaskShader == nil {
+		s, err := ebiten.NewShader(shadedMaskShaderSrc)
+		if err != nil {
+			return Material{}, err
+		}
+		shadedMaskShader = s
+	}
+	highlight, shadow := shading.colors()
+	return newMaskMaterial(tileSize, textureImage, maskImage, layout, varientMap, func(tiled, mask *ebiten.Image) (*ebiten.Image, error) {
+		b := mask.Bounds()
+		shaded := ebiten.NewImage(b.Dx(), b.Dy())
+		var opts ebiten.DrawRectShaderOptions
+		opts.Images[0] = mask
+		opts.Images[1] = tiled
+		opts.Uniforms = map[string]any{
+			"Highlight": highlight[:],
+			"Shadow":    shadow[:],
+		}
+		shaded.DrawRectShader(b.Dx(), b.Dy(), shadedMaskShader, &opts)
+		return shaded, nil
+	})
+}
@@ -0,0 +1,87 @@
+package dualgrid
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestShadedMask(t *testing.T) {
+	types := toRGBA(loadAsset(t, "materialTypes"))
+	rock := types.SubImage(image.Rect(0, 0, testTileSize, testTileSize))
+	mask := toRGBA(loadAsset(t, "rockMask"))
+
+	// Coverage only in red is the plain cut out of a white mask
+	coverage := bevelMask(mask, false)
+	white := image.NewRGBA(mask.Rect)
+	for i := 0; i < len(white.Pix); i += 4 {
+		if coverage.Pix[i] == 0xff {
+			copy(white.Pix[i:i+4], []byte{0xff, 0xff, 0xff, 0xff})
+		}
+	}
+	plain, err := NewMaterialFromMaskImage(testTileSize, rock, white, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shaded, err := NewMaterialFromShadedMaskImage(testTileSize, rock, coverage, LayoutJess, MaskShading{}, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(shaded.Pixels.Pix, plain.Pixels.Pix) {
+		t.Error("red coverage mask differs from the cut out mask")
+	}
+
+	highlight, shadow := (&MaskShading{Shadow: color.RGBA{0, 0, 64, 255}}).colors()
+	texel := []byte{200, 100, 50, 255}
+	for _, tt := range []struct {
+		mask, want []byte
+	}{
+		{[]byte{255, 0, 0, 255}, []byte{200, 100, 50, 255}},    // covered
+		{[]byte{0, 0, 0, 255}, []byte{0, 0, 0, 0}},             // uncovered
+		{[]byte{128, 0, 0, 255}, []byte{100, 50, 25, 128}},     // soft edge, premultiplied
+		{[]byte{255, 255, 0, 255}, []byte{255, 255, 255, 255}}, // full highlight
+		{[]byte{255, 0, 255, 255}, []byte{0, 0, 64, 255}},      // full shadow
+		{[]byte{255, 0, 128, 255}, []byte{100, 50, 57, 255}},   // half shadow
+	} {
+		got := make([]byte, 4)
+		stampShaded(got, texel, tt.mask, highlight, shadow)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("mask %v: texel = %v, want %v", tt.mask, got, tt.want)
+		}
+	}
+
+	// Soft alpha masks keep a valid premultiplied color
+	got := make([]byte, 4)
+	stamp(got, texel, []byte{128, 128, 128, 128})
+	if want := []byte{100, 50, 25, 128}; !slices.Equal(got, want) {
+		t.Errorf("soft mask texel = %v, want %v", got, want)
+	}
+}
+
+// bevelMask returns a shaded mask covering the opaque pixels of mask, with a highlight on their
+// top and left edges and a shadow on the bottom and right ones when bevel is set.
+func bevelMask(mask *image.RGBA, bevel bool) *image.RGBA {
+	covered := func(x, y int) bool {
+		return image.Pt(x, y).In(mask.Rect) && mask.RGBAAt(x, y).A == 0xff
+	}
+	shaded := image.NewRGBA(mask.Rect)
+	for y := range mask.Rect.Dy() {
+		for x := range mask.Rect.Dx() {
+			c := color.RGBA{A: 0xff}
+			if covered(x, y) {
+				c.R = 0xff
+				// tile borders are not edges, neighbours across them belong to other tiles
+				tx, ty := x%testTileSize, y%testTileSize
+				if bevel && (tx > 0 && !covered(x-1, y) || ty > 0 && !covered(x, y-1)) {
+					c.G = 0xa0
+				}
+				if bevel && (tx < testTileSize-1 && !covered(x+1, y) || ty < testTileSize-1 && !covered(x, y+1)) {
+					c.B = 0xa0
+				}
+			}
+			shaded.SetRGBA(x, y, c)
+		}
+	}
+	return shaded
+}
@@ -0,0 +1,337 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"image"
+	"slices"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+//go:embed dualGrid.kage
+var dualGridShaderSrc []byte
+
+// Compiled once and shared by every ShaderRenderer
+var dualGridShader *ebiten.Shader
+
+// ShaderRenderer is a Renderer that resolves corners, bitmasks and variants on the GPU.
+// WorldGrid is uploaded as a data texture and the whole view is drawn as one quad, so the
+// CPU cost does not depend on the number of visible tiles. Materials with a world texture
+// take one more quad each, their texture bound on its own.
+//
+// It produces the same output as the VertexRenderer, except that color scales and tints are
+// clamped to 0..1 per channel, being uploaded as bytes: brightening above 1 needs the
+// VertexRenderer. DualGrid.PickVarient is ignored, variants always follow VarientStrategy. A ShaderRenderer keeps per grid state, do not share one between DualGrids.
+//
+//	dg.Renderer, err = dualgrid.NewShaderRenderer()
+type ShaderRenderer struct {
+	grid *ebiten.Image
+	// What the grid texture was uploaded from, a change uploads it whole
+	cells      []TileType
+	def        TileType
+	rank       [256]int
+	tints      []Tint // nil when untinted
+	varients   *ebiten.Image
+	varientPix [2][]byte // uploaded varient table and scratch buffer
+	stride     int
+	// frame offset, packed color scale and world texture index of every atlas row
+	rows     []int
+	passes   []shaderPass
+	pix      []byte
+	vertices [4]ebiten.Vertex
+	opts     ebiten.DrawTrianglesShaderOptions
+}
+
+// shaderPass is one quad of a ShaderRenderer render, compositing the draw positions
+// [from, to) of the atlas rows using world texture world (1 + index in DualGrid.worlds, 0
+// for rows without one).
+type shaderPass struct {
+	from, to, world int
+}
+
+// NewShaderRenderer compiles the dual-grid shader on first use and returns a new ShaderRenderer.
+func NewShaderRenderer() (*ShaderRenderer, error) {
+	if dualGridShader == nil {
+		s, err := ebiten.NewShader(dualGridShaderSrc)
+		if err != nil {
+			return nil, err
+		}
+		dualGridShader = s
+	}
+	return &ShaderRenderer{}, nil
+}
+
+func (sr *ShaderRenderer) Render(dg *DualGrid, img *ebiten.Image, left, top int) {
+	buildStart := time.Now()
+	dg.ensureAtlas()
+	if dg.atlas == nil {
+		return
+	}
+	sr.uploadGrid(dg)
+	// Animated materials move their frame offset, material color scales and varient weights
+	// can change any time, all are stored in the varient table with the world texture indices
+	sr.rows = sr.rows[:0]
+	for i := range dg.Materials {
+		m := &dg.Materials[i]
+		scale := TintTransparent
+		if !m.Hidden {
+			scale = scaleTint(&m.ColorScale)
+		}
+		sr.rows = append(sr.rows, m.frameOffset(dg.Clock), int(packTint(scale)), dg.worldIndex[i])
+	}
+	for i := range dg.Transitions {
+		sr.rows = append(sr.rows, dg.Transitions[i].Material.frameOffset(dg.Clock), 0, dg.worldIndex[len(dg.Materials)+i])
+	}
+	sr.uploadVarients(dg)
+
+	// One quad over the whole image, custom carries dual-grid pixel positions
+	b := img.Bounds()
+	minX, minY := float32(b.Min.X), float32(b.Min.Y)
+	maxX, maxY := float32(b.Max.X), float32(b.Max.Y)
+	wl, wt := float32(left), float32(top)
+	wr, wb := wl+float32(b.Dx()), wt+float32(b.Dy())
+	sr.vertices = [4]ebiten.Vertex{
+		{DstX: minX, DstY: minY, Custom0: wl, Custom1: wt, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+		{DstX: maxX, DstY: minY, Custom0: wr, Custom1: wt, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+		{DstX: minX, DstY: maxY, Custom0: wl, Custom1: wb, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+		{DstX: maxX, DstY: maxY, Custom0: wr, Custom1: wb, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+	}
+
+	sr.opts.Images = [4]*ebiten.Image{dg.atlas, sr.grid, sr.varients, nil}
+	if sr.opts.Uniforms == nil {
+		sr.opts.Uniforms = map[string]any{}
+	}
+	sr.opts.Uniforms["TileSize"] = float32(dg.TileSize)
+	sr.opts.Uniforms["GridSize"] = []float32{float32(dg.WorldGrid.Width), float32(dg.WorldGrid.Height)}
+	sr.opts.Uniforms["MaterialCount"] = len(dg.Materials)
+	sr.opts.Uniforms["VarientStride"] = sr.stride
+	sr.opts.Uniforms["VarientStrategy"] = int(dg.VarientStrategy)
+	sr.opts.Uniforms["VarientClusterSize"] = dg.clusterSize()
+	sr.opts.Uniforms["VarientSeed"] = int(dg.Seed % varientPrime)
+	global := scaleTint(&dg.Options.ColorScale)
+	sr.opts.Uniforms["ColorScale"] = []float32{global.R, global.G, global.B, global.A}
+	sr.opts.Blend = dg.Options.Blend
+	sr.buildPasses(dg)
+
+	view := dg.newRenderView(b, left, top)
+	dg.stats.BuildTime = time.Since(buildStart)
+	dg.stats.VisibleTiles = dg.visibleTiles(&view)
+	dg.stats.Quads = dg.stats.Quads[:0] // no per tile geometry
+	dg.stats.VerticesReused = 0
+	dg.stats.VerticesAllocated = 0
+	dg.stats.DrawCalls = len(sr.passes)
+
+	for _, p := range sr.passes {
+		sr.opts.Uniforms["DrawRange"] = []int32{int32(p.from), int32(p.to)}
+		sr.opts.Uniforms["World"] = p.world
+		sr.opts.Images[3] = nil
+		if p.world > 0 {
+			sr.opts.Images[3] = dg.worlds[p.world-1]
+		}
+		img.DrawTrianglesShader(sr.vertices[:], dg.quadIndices(1), dualGridShader, &sr.opts)
+	}
+}
+
+// buildPasses splits the draw range into sr.passes. Materials without world textures share
+// one quad, each world texture takes one more for the draw position of its material: layers
+// of a single material never overlap, so its passes can go in any order.
+func (sr *ShaderRenderer) buildPasses(dg *DualGrid) {
+	_, order := dg.priorities()
+	from, to := dg.drawBounds(len(dg.Materials))
+	sr.passes = sr.passes[:0]
+	start := from
+	for p := from; p < to; p++ {
+		m := order[p]
+		if !dg.usesWorld(m) {
+			continue
+		}
+		if start < p {
+			sr.passes = append(sr.passes, shaderPass{from: start, to: p})
+		}
+		start = p + 1
+
+		// Rows of the material itself then of its transitions, each world texture once
+		first := len(sr.passes)
+		rows := []int{int(m)}
+		for i, t := range dg.Transitions {
+			if t.Upper == m {
+				rows = append(rows, len(dg.Materials)+i)
+			}
+		}
+		for _, row := range rows {
+			pass := shaderPass{from: p, to: p + 1, world: dg.worldIndex[row]}
+			if !slices.Contains(sr.passes[first:], pass) {
+				sr.passes = append(sr.passes, pass)
+			}
+		}
+	}
+	if start < to {
+		sr.passes = append(sr.passes, shaderPass{from: start, to: to})
+	}
+}
+
+// uploadGrid writes WorldGrid, and below it the cell tints, into the grid texture. Only the
+// cells changed since the last upload are written (see Grid.changed), unless the grid, its
+// tints, DefaultMaterial or the priorities were replaced.
+func (sr *ShaderRenderer) uploadGrid(dg *DualGrid) {
+	w, h := dg.WorldGrid.Width, dg.WorldGrid.Height
+	rank, _ := dg.priorities()
+	var tints []Tint
+	var tintChanged image.Rectangle
+	if dg.Tint != nil {
+		tints = dg.Tint.Tints
+		tintChanged = dg.Tint.changed
+		dg.Tint.changed = image.Rectangle{}
+	}
+	changed := dg.WorldGrid.changed.Union(tintChanged)
+	dg.WorldGrid.changed = image.Rectangle{}
+
+	if sr.grid == nil || sr.grid.Bounds().Dx() != w+2 || sr.grid.Bounds().Dy() != 2*(h+2) {
+		if sr.grid != nil {
+			sr.grid.Deallocate()
+		}
+		sr.grid = ebiten.NewImage(w+2, 2*(h+2))
+		sr.cells = nil
+	}
+	if !sameSlice(sr.cells, dg.WorldGrid.Cells) || sr.def != dg.DefaultMaterial || sr.rank != rank || !sameSlice(sr.tints, tints) {
+		changed = image.Rect(0, 0, max(w, 1), max(h, 1))
+	}
+	sr.cells = dg.WorldGrid.Cells
+	sr.def = dg.DefaultMaterial
+	sr.rank = rank
+	sr.tints = tints
+
+	if changed.Empty() {
+		return
+	}
+	// Texel (x+1, y+1) holds cell (x, y), border texels repeat the edge tints
+	r := changed.Add(image.Pt(1, 1)).Inset(-1).Intersect(image.Rect(0, 0, w+2, h+2))
+	n := 4 * r.Dx() * r.Dy()
+	sr.pix = slices.Grow(sr.pix[:0], 2*n)[:2*n]
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			v := dg.DefaultMaterial
+			if x >= 1 && y >= 1 && x <= w && y <= h {
+				v = dg.WorldGrid.Cells[(x-1)*h+(y-1)]
+			}
+			i := 4 * ((y-r.Min.Y)*r.Dx() + x - r.Min.X)
+			px := sr.pix[i:]
+			putTexelValue(px, int(v))
+			px[2] = byte(rank[v])
+
+			t := TintWhite
+			if dg.Tint != nil {
+				t = dg.Tint.At(x-1, y-1).premultiplied()
+			}
+			px = sr.pix[n+i:]
+			px[0], px[1], px[2], px[3] = byte(scale8(255, t.R)), byte(scale8(255, t.G)), byte(scale8(255, t.B)), byte(scale8(255, t.A))
+		}
+	}
+	sr.grid.SubImage(r).(*ebiten.Image).WritePixels(sr.pix[:n])
+	sr.grid.SubImage(r.Add(image.Pt(0, h+2))).(*ebiten.Image).WritePixels(sr.pix[n:])
+}
+
+// sameSlice reports whether a and b are the same slice, not only equal.
+func sameSlice[T any](a, b []T) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// uploadVarients writes every material and transition VarientMap, with the varient thresholds
+// and the frame offset, color scale and world texture index of sr.rows, into the varient table
+// texture when they changed since the last upload. The material pair table follows on the
+// material rows, see writePairs.
+func (sr *ShaderRenderer) uploadVarients(dg *DualGrid) {
+	mats := make([]*Material, 0, dg.atlasRows())
+	for i := range dg.Materials {
+		mats = append(mats, &dg.Materials[i])
+	}
+	for i := range dg.Transitions {
+		mats = append(mats, &dg.Transitions[i].Material)
+	}
+
+	var longest int
+	for _, m := range mats {
+		for _, v := range m.VarientMap {
+			longest = max(longest, len(v))
+		}
+	}
+	stride := 1 + 2*longest
+
+	w, h := 16*stride+3+len(dg.Materials), max(len(mats), 1)
+	pix := slices.Grow(sr.varientPix[1][:0], 4*w*h)[:4*w*h]
+	clear(pix)
+	for m, mat := range mats {
+		for bitmask, v := range mat.VarientMap {
+			x := bitmask * stride
+			putTexelValue(pix[4*(m*w+x):], len(v))
+			pix[4*(m*w+x)+2] = byte(mat.Orientations[bitmask].keeping(bitmask))
+			var cum int
+			for i, slot := range v {
+				weight, total := varientWeight(i, len(v), mat.VarientWeights[bitmask])
+				cum += weight
+				putTexelValue(pix[4*(m*w+x+1+i):], slot)
+				putTexelValue(pix[4*(m*w+x+1+longest+i):], varientThreshold(cum, total))
+			}
+		}
+		x := 16 * stride
+		putTexelValue(pix[4*(m*w+x):], sr.rows[3*m])
+		binary.LittleEndian.PutUint32(pix[4*(m*w+x+1):], uint32(sr.rows[3*m+1]))
+		putTexelValue(pix[4*(m*w+x+2):], sr.rows[3*m+2])
+	}
+	writePairs(dg, pix, w, 16*stride+3)
+
+	sr.varientPix[1] = pix
+	if sr.varients != nil && sr.stride == stride && sr.varients.Bounds().Dy() == h && slices.Equal(sr.varientPix[0], pix) {
+		return
+	}
+	if sr.varients == nil || sr.varients.Bounds().Dx() != w || sr.varients.Bounds().Dy() != h {
+		if sr.varients != nil {
+			sr.varients.Deallocate()
+		}
+		sr.varients = ebiten.NewImage(w, h)
+	}
+	sr.varients.WritePixels(pix)
+	sr.stride = stride
+	sr.varientPix[0], sr.varientPix[1] = sr.varientPix[1], sr.varientPix[0]
+}
+
+// writePairs writes the transition and connection of every (m, other) material pair into the
+// varient table pix of width w, texel (x+other, m). Connects can change at any time, so the
+// table is rebuilt every render and only uploaded on change.
+func writePairs(dg *DualGrid, pix []byte, w, x int) {
+	for i, t := range dg.Transitions {
+		if int(t.Upper) >= len(dg.Materials) || int(t.Lower) >= len(dg.Materials) {
+			continue
+		}
+		px := pix[4*(int(t.Upper)*w+x+int(t.Lower)):]
+		if px[3] == 0 { // first registration wins, like transitionIndex
+			putTexelValue(px, i+1)
+		}
+	}
+	for m := range len(dg.Materials) {
+		for other := range len(dg.Materials) {
+			px := pix[4*(m*w+x+other):]
+			px[3] = 0xff
+			if dg.connects(TileType(m), TileType(other)) {
+				px[2] = 0xff
+			}
+		}
+	}
+}
+
+// packTint returns t as RGBA bytes in 0-255 units (little endian), clamped to 0..1.
+func packTint(t Tint) uint32 {
+	return scale8(255, t.R) | scale8(255, t.G)<<8 | scale8(255, t.B)<<16 | scale8(255, t.A)<<24
+}
+
+// putTexelValue stores v as an opaque texel, low byte in red and high byte in green.
+func putTexelValue(px []byte, v int) {
+	px[0] = byte(v)
+	px[1] = byte(v >> 8)
+	px[2] = 0
+	px[3] = 0xff
+}
@@ -0,0 +1,119 @@
+package dualgrid
+
+import (
+	"image"
+	"slices"
+	"time"
+)
+
+// SoftwareRenderer is a pure Go Renderer drawing on the CPU, matching the VertexRenderer up to
+// one unit of GPU rounding per channel (see TestVertexRendererMatchesSoftware).
+// Only materials with Pixels (built from image.Image sources) are drawn, others are skipped,
+// as are world texture materials without WorldPixels.
+//
+// RenderRGBA (and DualGrid.DrawToRGBA) never call ebiten, use them where ebiten cannot run,
+// e.g. generating map thumbnails on a headless server.
+type SoftwareRenderer struct {
+	buf *image.RGBA
+	softwareUpload
+	oriented *image.RGBA // scratch tile for layers with an Orientation
+	stamped  *image.RGBA // scratch tile for layers with a world texture
+}
+
+// RenderRGBA draws the DualGrid over dst, dst's top-left pixel being the world pixel (left, top).
+func (sr *SoftwareRenderer) RenderRGBA(dg *DualGrid, dst *image.RGBA, left, top int) {
+	buildStart := time.Now()
+	b := dst.Bounds()
+	view := dg.newRenderView(b, left, top)
+	ts := dg.TileSize
+
+	numMats := len(dg.Materials)
+	scales := dg.materialScales()
+	rank, _ := dg.priorities()
+	from, to := dg.drawBounds(numMats)
+	dg.stats.Quads = slices.Grow(dg.stats.Quads[:0], numMats)[:numMats]
+	clear(dg.stats.Quads)
+	dg.ResolveRect(view.tileStartX, view.tileStartY, view.widthInTile, view.heightInTile, func(tileX, tileY int, layers []Layer) {
+		dstX := tileX*ts - left + b.Min.X
+		dstY := tileY*ts - top + b.Min.Y
+		for _, l := range layers {
+			m := dg.layerMaterial(l)
+			if m.Pixels == nil || m.isWorld() && m.WorldPixels == nil || rank[l.Material] < from || rank[l.Material] >= to {
+				continue
+			}
+			src, srcX := m.Pixels, (m.frameOffset(dg.Clock)+l.Slot)*ts
+			if l.Orientation != OrientIdentity {
+				if sr.oriented == nil || sr.oriented.Rect.Dx() != ts {
+					sr.oriented = image.NewRGBA(image.Rect(0, 0, ts, ts))
+				}
+				orientTile(sr.oriented, image.Point{}, src, image.Pt(srcX, 0), ts, l.Orientation)
+				src, srcX = sr.oriented, 0
+			}
+			if m.WorldPixels != nil {
+				if sr.stamped == nil || sr.stamped.Rect.Dx() != ts {
+					sr.stamped = image.NewRGBA(image.Rect(0, 0, ts, ts))
+				}
+				stampWorld(sr.stamped, src, srcX, m.WorldPixels, tileX*ts-ts/2, tileY*ts-ts/2, ts)
+				src, srcX = sr.stamped, 0
+			}
+			if scale := scales[l.Material]; dg.Tint != nil || scale != TintWhite {
+				tl, tr, bl, br := dg.cornerTints(tileX, tileY)
+				corners := [4]Tint{tl.premultiplied().mul(scale), tr.premultiplied().mul(scale), bl.premultiplied().mul(scale), br.premultiplied().mul(scale)}
+				drawTileTinted(dst, dstX, dstY, src, srcX, ts, corners)
+			} else {
+				drawTile(dst, dstX, dstY, src, srcX, ts)
+			}
+			dg.stats.Quads[l.Material]++
+		}
+	})
+
+	dg.stats.BuildTime = time.Since(buildStart)
+	dg.stats.VisibleTiles = dg.visibleTiles(&view)
+	dg.stats.VerticesReused = 0
+	dg.stats.VerticesAllocated = 0
+	dg.stats.DrawCalls = 0
+}
+
+// drawTile composites the tileSize square at (srcX, 0) of src over dst at (dstX, dstY),
+// with the premultiplied source-over blending ebiten uses by default.
+func drawTile(dst *image.RGBA, dstX, dstY int, src *image.RGBA, srcX, tileSize int) {
+	r := image.Rect(dstX, dstY, dstX+tileSize, dstY+tileSize).Intersect(dst.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		d := dst.Pix[dst.PixOffset(r.Min.X, y):]
+		s := src.Pix[src.PixOffset(srcX+r.Min.X-dstX, y-dstY):]
+		for i := 0; i < 4*r.Dx(); i += 4 {
+			inv := 255 - uint32(s[i+3])
+			d[i+0] = uint8(min(uint32(s[i+0])+(uint32(d[i+0])*inv+127)/255, 255))
+			d[i+1] = uint8(min(uint32(s[i+1])+(uint32(d[i+1])*inv+127)/255, 255))
+			d[i+2] = uint8(min(uint32(s[i+2])+(uint32(d[i+2])*inv+127)/255, 255))
+			d[i+3] = uint8(min(uint32(s[i+3])+(uint32(d[i+3])*inv+127)/255, 255))
+		}
+	}
+}
+
+// drawTileTinted is drawTile with the source multiplied by the premultiplied corner tints
+// (TL, TR, BL, BR) interpolated at each pixel center, like vertex colors.
+func drawTileTinted(dst *image.RGBA, dstX, dstY int, src *image.RGBA, srcX, tileSize int, corners [4]Tint) {
+	r := image.Rect(dstX, dstY, dstX+tileSize, dstY+tileSize).Intersect(dst.Rect)
+	ts := float32(tileSize)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		d := dst.Pix[dst.PixOffset(r.Min.X, y):]
+		s := src.Pix[src.PixOffset(srcX+r.Min.X-dstX, y-dstY):]
+		v := (float32(y-dstY) + 0.5) / ts
+		for i := 0; i < 4*r.Dx(); i += 4 {
+			u := (float32(r.Min.X+i/4-dstX) + 0.5) / ts
+			t := tintAt(corners[0], corners[1], corners[2], corners[3], u, v)
+			sr, sg, sb, sa := scale8(s[i+0], t.R), scale8(s[i+1], t.G), scale8(s[i+2], t.B), scale8(s[i+3], t.A)
+			inv := 255 - sa
+			d[i+0] = uint8(min(sr+(uint32(d[i+0])*inv+127)/255, 255))
+			d[i+1] = uint8(min(sg+(uint32(d[i+1])*inv+127)/255, 255))
+			d[i+2] = uint8(min(sb+(uint32(d[i+2])*inv+127)/255, 255))
+			d[i+3] = uint8(min(sa+(uint32(d[i+3])*inv+127)/255, 255))
+		}
+	}
+}
+
+// scale8 multiplies a 0-255 value by f, rounded and clamped to 0-255.
+func scale8(v uint8, f float32) uint32 {
+	return uint32(min(max(float32(v)*f+0.5, 0), 255))
+}
@@ -0,0 +1,34 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// softwareUpload is the image a SoftwareRenderer uploads its CPU render to.
+type softwareUpload struct {
+	upload *ebiten.Image
+}
+
+// Render draws on the CPU, then uploads the result over img.
+func (sr *SoftwareRenderer) Render(dg *DualGrid, img *ebiten.Image, left, top int) {
+	b := img.Bounds()
+	if sr.buf == nil || sr.buf.Rect.Size() != b.Size() {
+		sr.buf = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		if sr.upload != nil {
+			sr.upload.Deallocate()
+		}
+		sr.upload = ebiten.NewImage(b.Dx(), b.Dy())
+	} else {
+		clear(sr.buf.Pix)
+	}
+	sr.RenderRGBA(dg, sr.buf, left, top)
+	sr.upload.WritePixels(sr.buf.Pix)
+
+	var opts ebiten.DrawImageOptions
+	opts.GeoM.Translate(float64(b.Min.X), float64(b.Min.Y))
+	img.DrawImage(sr.upload, &opts)
+}
@@ -0,0 +1,40 @@
+package dualgrid
+
+import "time"
+
+// RenderStats describes the work done by the last render (DrawTo, Canvas redraw,
+// ViewCanvas or RedrawCanvasRegion). Get a snapshot with DualGrid.Stats.
+type RenderStats struct {
+	VisibleTiles int   // Dual-grid tiles inside both the view and the grid
+	Quads        []int // Quads emitted per material index, after opaque culling
+	DrawCalls    int
+
+	// Vertex buffers, stripe buffers and batch combined
+	VerticesAllocated int // Capacity newly allocated by this render
+	VerticesReused    int // Vertices written to capacity carried over from previous renders
+
+	BuildTime time.Duration // Time spent building geometry (or uploading data for the ShaderRenderer)
+}
+
+// TotalQuads returns the number of quads emitted for every material.
+func (s RenderStats) TotalQuads() int {
+	var n int
+	for _, q := range s.Quads {
+		n += q
+	}
+	return n
+}
+
+// Stats returns a snapshot of the last render statistics.
+func (dg *DualGrid) Stats() RenderStats {
+	s := dg.stats
+	s.Quads = append([]int(nil), dg.stats.Quads...)
+	return s
+}
+
+// visibleTiles counts the tiles of view that fall inside the dual grid.
+func (dg *DualGrid) visibleTiles(view *renderView) int {
+	w := min(view.tileStartX+view.widthInTile, dg.WorldGrid.Width+1) - max(view.tileStartX, 0)
+	h := min(view.tileStartY+view.heightInTile, dg.WorldGrid.Height+1) - max(view.tileStartY, 0)
+	return max(w, 0) * max(h, 0)
+}
@@ -0,0 +1,14 @@
+package dualgrid
+
+// connects reports whether the corner material counts as matType for the edges of matType,
+// on top of corners drawn at or above it (see DualGrid.Connects and Material.Terrain).
+func (dg *DualGrid) connects(matType, corner TileType) bool {
+	if int(corner) >= len(dg.Materials) {
+		return false
+	}
+	if dg.Connects != nil {
+		return dg.Connects(matType, corner)
+	}
+	t := dg.Materials[matType].Terrain
+	return t != 0 && t == dg.Materials[corner].Terrain
+}
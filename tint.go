@@ -0,0 +1,98 @@
+package dualgrid
+
+import "image"
+
+// Tint is a color multiplied into the material textures, components from 0 to 1.
+// White leaves tiles unchanged, darker colors shade them (lighting, day/night),
+// and a lower A fades them out (straight alpha, like ebiten vertex colors).
+type Tint struct {
+	R, G, B, A float32
+}
+
+var (
+	TintWhite       = Tint{1, 1, 1, 1} // leaves tiles unchanged
+	TintTransparent = Tint{0, 0, 0, 0}
+)
+
+// TintGrid holds one Tint per cell, laid out like Grid.
+//
+// Dual-grid tile corners sit on cell centers, so each cell Tint goes to the matching quad
+// vertex and is interpolated across the four tiles around it.
+type TintGrid struct {
+	Width, Height int
+	Tints         []Tint
+	// Cells changed by Set and FillRect since the ShaderRenderer last uploaded them, see
+	// DualGrid.MarkDirty for direct Tints writes
+	changed image.Rectangle
+}
+
+// NewTintGrid returns a width x height TintGrid filled with TintWhite.
+func NewTintGrid(width, height int) TintGrid {
+	tints := make([]Tint, width*height)
+	for i := range tints {
+		tints[i] = TintWhite
+	}
+	return TintGrid{Width: width, Height: height, Tints: tints, changed: image.Rect(0, 0, width, height)}
+}
+
+// Set sets the Tint of the given cell.
+func (tg *TintGrid) Set(x, y int, t Tint) {
+	tg.changed = tg.changed.Union(image.Rect(x, y, x+1, y+1))
+	tg.Tints[x*tg.Height+y] = t
+}
+
+// At returns the Tint of the given cell, cells outside the grid take the nearest one.
+func (tg *TintGrid) At(x, y int) Tint {
+	if tg.Width == 0 || tg.Height == 0 {
+		return TintWhite
+	}
+	x = min(max(x, 0), tg.Width-1)
+	y = min(max(y, 0), tg.Height-1)
+	return tg.Tints[x*tg.Height+y]
+}
+
+// FillRect sets the Tint of a rectangle of cells.
+// x, y is the top-left corner; w, h are width and height.
+func (tg *TintGrid) FillRect(x, y, w, h int, t Tint) {
+	tg.changed = tg.changed.Union(image.Rect(x, y, x+w, y+h))
+	for dx := range w {
+		for dy := range h {
+			tg.Tints[(x+dx)*tg.Height+(y+dy)] = t
+		}
+	}
+}
+
+// cornerTints returns the Tint of the four corners of the dual-grid tile (tileX, tileY).
+func (dg *DualGrid) cornerTints(tileX, tileY int) (tl, tr, bl, br Tint) {
+	if dg.Tint == nil {
+		return TintWhite, TintWhite, TintWhite, TintWhite
+	}
+	return dg.Tint.At(tileX-1, tileY-1), dg.Tint.At(tileX, tileY-1), dg.Tint.At(tileX-1, tileY), dg.Tint.At(tileX, tileY)
+}
+
+// premultiplied returns t with its color multiplied by its alpha, as ebiten does with vertex colors.
+func (t Tint) premultiplied() Tint {
+	return Tint{t.R * t.A, t.G * t.A, t.B * t.A, t.A}
+}
+
+// tintAt interpolates the corner tints at (u, v) in 0..1 across a tile, over the same two
+// triangles (TL, TR, BL) and (TR, BR, BL) the quad is drawn with.
+func tintAt(tl, tr, bl, br Tint, u, v float32) Tint {
+	lerp := func(o, a, b float32, u, v float32) float32 {
+		return o + (a-o)*u + (b-o)*v
+	}
+	if u+v <= 1 {
+		return Tint{
+			lerp(tl.R, tr.R, bl.R, u, v),
+			lerp(tl.G, tr.G, bl.G, u, v),
+			lerp(tl.B, tr.B, bl.B, u, v),
+			lerp(tl.A, tr.A, bl.A, u, v),
+		}
+	}
+	return Tint{
+		lerp(br.R, bl.R, tr.R, 1-u, 1-v),
+		lerp(br.G, bl.G, tr.G, 1-u, 1-v),
+		lerp(br.B, bl.B, tr.B, 1-u, 1-v),
+		lerp(br.A, bl.A, tr.A, 1-u, 1-v),
+	}
+}
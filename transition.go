@@ -0,0 +1,51 @@
+package dualgrid
+
+// Transition replaces the edge tiles of Upper where it is drawn directly over Lower,
+// e.g. a sand shoreline for grass meeting water that grass meeting rock does not get.
+//
+// Material is built like any other material (same slot layout, VarientMap and Opaque),
+// but is not a TileType and cannot be painted.
+type Transition struct {
+	Upper    TileType
+	Lower    TileType
+	Material Material
+}
+
+// AddTransition registers m as the edge tiles of upper where the layer right below it is lower.
+// Registering the same pair again replaces its material.
+func (dg *DualGrid) AddTransition(upper, lower TileType, m Material) {
+	if i := dg.transitionIndex(upper, lower); i >= 0 {
+		dg.Transitions[i].Material = m
+	} else {
+		dg.Transitions = append(dg.Transitions, Transition{Upper: upper, Lower: lower, Material: m})
+	}
+	dg.dropAtlas()
+	dg.dirty = true
+}
+
+// transitionIndex returns the index in dg.Transitions of the (upper, lower) pair, or -1.
+func (dg *DualGrid) transitionIndex(upper, lower TileType) int {
+	for i := range dg.Transitions {
+		if dg.Transitions[i].Upper == upper && dg.Transitions[i].Lower == lower {
+			return i
+		}
+	}
+	return -1
+}
+
+// layerMaterial returns the material whose texture draws l.
+func (dg *DualGrid) layerMaterial(l Layer) *Material {
+	if l.Transition > 0 {
+		return &dg.Transitions[l.Transition-1].Material
+	}
+	return &dg.Materials[l.Material]
+}
+
+// atlasRow returns the atlas row holding the texture that draws l.
+// Transition textures are packed below every material.
+func (dg *DualGrid) atlasRow(l Layer) int {
+	if l.Transition > 0 {
+		return len(dg.Materials) + l.Transition - 1
+	}
+	return int(l.Material)
+}
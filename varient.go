@@ -0,0 +1,97 @@
+package dualgrid
+
+// VarientStrategy selects how a DualGrid picks the variant of each tile among the slots of
+// Material.VarientMap, weighted by Material.VarientWeights. Every strategy is deterministic,
+// a tile always shows the same variant for a given Seed.
+type VarientStrategy int
+
+const (
+	// VarientHash picks the variant of every tile independently, from a hash of its position.
+	VarientHash VarientStrategy = iota
+	// VarientClustered picks one variant per patch of about VarientClusterSize tiles with
+	// noisy edges, so large areas keep the same look instead of a salt and pepper mix.
+	VarientClustered
+)
+
+// varientPrime is the range of the varient hashes. Every product stays below 2^24 so the
+// shader computes the same values as Go.
+const varientPrime = 4093
+
+// varientHash returns a hash of the tile (x, y) in [0, varientPrime), x and y >= 0.
+func varientHash(x, y, seed int) int {
+	a, b := x%varientPrime, y%varientPrime
+	h := (a*1619 + seed) % varientPrime
+	h = (h*h%varientPrime + b*2531) % varientPrime
+	h = (h*h%varientPrime + a*3187) % varientPrime
+	h = (h*h%varientPrime + b*1087) % varientPrime
+	return h
+}
+
+// varientRoll returns the value in [0, varientPrime) the variant of the tile is picked with.
+func (dg *DualGrid) varientRoll(tileX, tileY int) int {
+	seed := int(dg.Seed % varientPrime)
+	if dg.VarientStrategy != VarientClustered {
+		return varientHash(tileX, tileY, seed)
+	}
+	// Square patches, tiles randomly joining the next patch for ragged edges
+	s := dg.clusterSize()
+	h := varientHash(tileX, tileY, seed)
+	cx := (tileX + h%2) / s
+	cy := (tileY + h/2%2) / s
+	return varientHash(cx+1, cy+1, seed)
+}
+
+// clusterSize returns the patch size of VarientClustered, in tiles.
+func (dg *DualGrid) clusterSize() int {
+	if dg.VarientClusterSize <= 0 {
+		return 4
+	}
+	return dg.VarientClusterSize
+}
+
+// varientWeight returns the weight of the i-th of count variants and the total weight.
+// Missing weights count as 1, negative ones as 0, and all 0 as equal weights.
+func varientWeight(i, count int, weights []int) (weight, total int) {
+	at := func(j int) int {
+		if j >= len(weights) {
+			return 1
+		}
+		return max(weights[j], 0)
+	}
+	for j := range count {
+		total += at(j)
+	}
+	if total == 0 {
+		return 1, count
+	}
+	return at(i), total
+}
+
+// varientThreshold scales the cumulative weight cum to [0, varientPrime], the variant picked
+// is the first one whose threshold is above the roll.
+func varientThreshold(cum, total int) int {
+	return int(int64(cum) * varientPrime / int64(total))
+}
+
+// varientIndex returns the index of the Material.VarientMap[bitmask] entry of m shown on the
+// tile, m being matType or its transition. VarientMap[bitmask] must not be empty.
+func (dg *DualGrid) varientIndex(tileX, tileY int, matType TileType, m *Material, bitmask int) int {
+	count := len(m.VarientMap[bitmask])
+	if dg.PickVarient != nil {
+		i := dg.PickVarient(tileX, tileY, matType, bitmask, count) % count
+		if i < 0 {
+			i += count
+		}
+		return i
+	}
+	roll := dg.varientRoll(tileX, tileY)
+	var cum int
+	for i := range count - 1 {
+		weight, total := varientWeight(i, count, m.VarientWeights[bitmask])
+		cum += weight
+		if varientThreshold(cum, total) > roll {
+			return i
+		}
+	}
+	return count - 1
+}
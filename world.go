@@ -0,0 +1,65 @@
+package dualgrid
+
+import "image"
+
+// NewMaterialFromMaskWorldImage builds the same Material as NewMaterialFromMaskWorld from
+// image.Image sources, on the CPU and without any ebiten call. See NewMaterialFromTilemapImage.
+func NewMaterialFromMaskWorldImage(tileSize int, textureImage, maskImage image.Image, layout TilemapLayout, varientMap VarientMap) (Material, error) {
+	if err := checkWorldTexture(textureImage.Bounds()); err != nil {
+		return Material{}, err
+	}
+	sources, _ := tilemapLayout(&layout, varientMap)
+	if err := layout.check(tileSize, maskImage.Bounds(), sources, MaskDimensionError); err != nil {
+		return Material{}, err
+	}
+
+	m, err := NewMaterialFromTilemapImageLayout(tileSize, maskImage, layout, varientMap)
+	if err != nil {
+		return Material{}, err
+	}
+	m.WorldPixels = toRGBA(textureImage)
+	if !opaqueImage(m.WorldPixels) {
+		clear(m.Opaque)
+	}
+	return m, nil
+}
+
+// checkWorldTexture validates the size of a world texture.
+func checkWorldTexture(b image.Rectangle) error {
+	if b.Empty() {
+		return TextureDimensionError
+	}
+	return nil
+}
+
+// stampWorld writes the size x size tile of mask at (maskX, 0) into dst at (0, 0), multiplied
+// by world sampled from the world pixel (worldX, worldY) on, see stamp.
+func stampWorld(dst *image.RGBA, mask *image.RGBA, maskX int, world *image.RGBA, worldX, worldY, size int) {
+	w, h := world.Rect.Dx(), world.Rect.Dy()
+	for y := range size {
+		wy := floorMod(worldY+y, h)
+		for x := range size {
+			t := world.Pix[world.PixOffset(floorMod(worldX+x, w), wy):]
+			stamp(dst.Pix[dst.PixOffset(x, y):], t, mask.Pix[mask.PixOffset(maskX+x, y):])
+		}
+	}
+}
+
+// opaqueImage reports whether every pixel of img has full alpha.
+func opaqueImage(img *image.RGBA) bool {
+	w := img.Rect.Dx()
+	for y := range img.Rect.Dy() {
+		row := img.Pix[y*img.Stride : y*img.Stride+4*w]
+		for x := 3; x < len(row); x += 4 {
+			if row[x] != 0xff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// floorMod returns a modulo b in [0, b), b > 0.
+func floorMod(a, b int) int {
+	return a - floorDiv(a, b)*b
+}
@@ -0,0 +1,82 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	_ "embed"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+//go:embed world.kage
+var worldShaderSrc []byte
+
+// Compiled on the first VertexRenderer draw of a world texture, shared by every DualGrid
+var worldShader *ebiten.Shader
+
+// NewMaterialFromMaskWorld takes a texture of any size and a mask laid out as layout and builds
+// a Material showing the texture tiled across the whole map in world space, the mask tiles
+// still cutting the shape of every bitmask. A large seamless texture (rock, water) then does
+// not repeat every tile:
+//
+//	rock, err := dualgrid.NewMaterialFromMaskWorld(16, rock256, rockMask, dualgrid.LayoutJess, dualgrid.VarientMap{})
+//
+// The texture is sampled at the world pixel position, cell (x, y) covering
+// [x*TileSize, (x+1)*TileSize) like for TileOffset, its top-left corner at the origin.
+// The texture is bound on its own when drawing, it can be as large as the GPU allows.
+func NewMaterialFromMaskWorld(tileSize int, textureImage, maskImage *ebiten.Image, layout TilemapLayout, varientMap VarientMap) (Material, error) {
+	if err := checkWorldTexture(textureImage.Bounds()); err != nil {
+		return Material{}, err
+	}
+	sources, _ := tilemapLayout(&layout, varientMap)
+	if err := layout.check(tileSize, maskImage.Bounds(), sources, MaskDimensionError); err != nil {
+		return Material{}, err
+	}
+
+	// the mask tiles are the material texture, stamped at draw time
+	m, err := NewMaterialFromTilemapLayout(tileSize, maskImage, layout, varientMap)
+	if err != nil {
+		return Material{}, err
+	}
+	m.WorldTexture = textureImage
+	return m, nil
+}
+
+// isWorld reports whether the material has a world texture.
+func (m *Material) isWorld() bool {
+	return m.WorldTexture != nil || m.WorldPixels != nil
+}
+
+// worldTexture returns the material WorldTexture, uploading WorldPixels first if needed.
+func (m *Material) worldTexture() *ebiten.Image {
+	if m.WorldTexture == nil && m.WorldPixels != nil {
+		m.WorldTexture = ebiten.NewImageFromImage(m.WorldPixels)
+	}
+	return m.WorldTexture
+}
+
+// usesWorld reports whether material i or one of its transitions has a world texture, its
+// quads then going through the world shader.
+func (dg *DualGrid) usesWorld(i TileType) bool {
+	if dg.Materials[i].isWorld() {
+		return true
+	}
+	for _, t := range dg.Transitions {
+		if t.Upper == i && t.Material.isWorld() {
+			return true
+		}
+	}
+	return false
+}
+
+// worldMaskShader returns the shader drawing world texture quads, compiling it on first use.
+func worldMaskShader() *ebiten.Shader {
+	if worldShader == nil {
+		s, err := ebiten.NewShader(worldShaderSrc)
+		if err != nil {
+			panic("dualgrid: world shader: " + err.Error())
+		}
+		worldShader = s
+	}
+	return worldShader
+}
@@ -0,0 +1,109 @@
+//go:build !dualgrid_headless
+
+package dualgrid
+
+import (
+	"image"
+	"slices"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestVertexRendererWorldTexture(t *testing.T) {
+	dg := testGrid(4, 4, 3, 0)
+	dg.WorldGrid.FillRect(1, 1, 2, 2, 1)
+	dg.SetCell(3, 3, 2)
+	dg.Materials[1].WorldTexture = ebiten.NewImage(40, 24)
+
+	img := ebiten.NewImage(5*testTileSize, 5*testTileSize)
+	dg.DrawTo(img, 0, 0)
+
+	// The world texture is bound on its own, the atlas only holds the strips
+	if got := dg.worldIndex; len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 0 || len(dg.worlds) != 1 {
+		t.Fatalf("worldIndex = %v with %d world textures, want [0 1 0] with 1", got, len(dg.worlds))
+	}
+	if b := dg.atlas.Bounds(); b.Dx() != 16*testTileSize || b.Dy() != 3*testTileSize {
+		t.Errorf("atlas size = %v, want %dx%d", b.Size(), 16*testTileSize, 3*testTileSize)
+	}
+
+	// Material 1 goes through the world shader, splitting the batch in three draw calls
+	if s := dg.Stats(); s.DrawCalls != 3 {
+		t.Errorf("DrawCalls = %d, want 3", s.DrawCalls)
+	}
+	for p, end := range dg.batchEnds {
+		start := 0
+		if p > 0 {
+			start = dg.batchEnds[p-1]
+		}
+		want := float32(0)
+		if p == 1 {
+			want = 1
+		}
+		for _, v := range dg.batch[start:end] {
+			if v.Custom2 != want {
+				t.Fatalf("material %d vertex has world texture %v, want %v", p, v.Custom2, want)
+			}
+		}
+	}
+}
+
+func TestShaderRendererWorldPasses(t *testing.T) {
+	dg := testGrid(4, 4, 3, 0)
+	dg.WorldGrid.FillRect(1, 1, 2, 2, 1)
+	dg.SetCell(3, 3, 2)
+	dg.Materials[1].WorldTexture = ebiten.NewImage(40, 24)
+	edge := Material{TileSize: testTileSize, TileCount: 16}
+	edge.WorldTexture = ebiten.NewImage(8, 8)
+	dg.AddTransition(1, 0, edge)
+	sr, err := NewShaderRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dg.Renderer = sr
+	dg.DrawTo(ebiten.NewImage(5*testTileSize, 5*testTileSize), 0, 0)
+
+	// Material 0, material 1 with each of its two world textures, then material 2
+	want := []shaderPass{{0, 1, 0}, {1, 2, 1}, {1, 2, 2}, {2, 3, 0}}
+	if !slices.Equal(sr.passes, want) {
+		t.Errorf("passes = %v, want %v", sr.passes, want)
+	}
+	if s := dg.Stats(); s.DrawCalls != len(want) {
+		t.Errorf("DrawCalls = %d, want %d", s.DrawCalls, len(want))
+	}
+}
+
+func TestWorldTextureSubImage(t *testing.T) {
+	for _, renderer := range []string{"vertex", "shader"} {
+		t.Run(renderer, func(t *testing.T) {
+			dg := simpleWorldGrid(t)
+			if renderer == "shader" {
+				sr, err := NewShaderRenderer()
+				if err != nil {
+					t.Fatal(err)
+				}
+				dg.Renderer = sr
+			}
+			// Move the world texture away from the origin of its backing image, the shaders
+			// reading it in the coordinates of the atlas
+			rock := &dg.Materials[0]
+			texture := rock.worldTexture()
+			b := texture.Bounds()
+			backing := ebiten.NewImage(b.Dx()+13, b.Dy()+7)
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(13, 7)
+			backing.DrawImage(texture, op)
+			rock.WorldTexture = backing.SubImage(image.Rect(13, 7, 13+b.Dx(), 7+b.Dy())).(*ebiten.Image)
+
+			w := (dg.WorldGrid.Width + 1) * dg.TileSize
+			h := (dg.WorldGrid.Height + 1) * dg.TileSize
+			img := ebiten.NewImage(w, h)
+			dg.DrawTo(img, -7, 13)
+			got := readPixels(t, img)
+
+			want := image.NewRGBA(got.Rect)
+			dg.DrawToRGBA(want, -7, 13)
+			checkPixels(t, got, want)
+		})
+	}
+}
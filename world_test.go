@@ -0,0 +1,65 @@
+package dualgrid
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestWorldMaterial(t *testing.T) {
+	types := toRGBA(loadAsset(t, "materialTypes"))
+	rock := types.SubImage(image.Rect(0, 0, testTileSize, testTileSize))
+
+	// A tile sized world texture shifted by the half tile of the dual grid lines up with every
+	// tile, like a mask material
+	half := testTileSize / 2
+	shifted := image.NewRGBA(image.Rect(0, 0, testTileSize, testTileSize))
+	for _, q := range []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		draw.Draw(shifted, shifted.Rect.Add(q.Mul(testTileSize).Sub(image.Pt(half, half))), rock, image.Point{}, draw.Src)
+	}
+	world, err := NewMaterialFromMaskWorldImage(testTileSize, shifted, loadAsset(t, "rockMask"), LayoutJess, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := natureGrid(t)
+	got := natureGrid(t)
+	got.Materials[0] = world
+	wantImg := image.NewRGBA(image.Rect(0, 0, 17*testTileSize, 17*testTileSize))
+	gotImg := image.NewRGBA(wantImg.Rect)
+	want.DrawToRGBA(wantImg, -5, 3)
+	got.DrawToRGBA(gotImg, -5, 3)
+	for y := range wantImg.Rect.Dy() {
+		for x := range wantImg.Rect.Dx() {
+			if g, w := gotImg.RGBAAt(x, y), wantImg.RGBAAt(x, y); g != w {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, g, w)
+			}
+		}
+	}
+
+	if _, err := NewMaterialFromMaskWorldImage(testTileSize, image.NewRGBA(image.Rect(0, 0, 0, 8)), loadAsset(t, "rockMask"), LayoutJess, VarientMap{}); !errors.Is(err, TextureDimensionError) {
+		t.Errorf("empty world texture: err = %v, want TextureDimensionError", err)
+	}
+}
+
+func TestWorldOpacity(t *testing.T) {
+	world := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(world, world.Rect, image.Opaque, image.Point{}, draw.Src)
+	m, err := NewMaterialFromMaskWorldImage(testTileSize, world, loadAsset(t, "rockMask"), LayoutJess, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.isOpaque(0b1111) {
+		t.Error("full tile of an opaque world texture is not opaque")
+	}
+
+	// A see through pixel anywhere in the world texture shows on some full tile
+	world.Pix[4*100+3] = 0x80
+	m, err = NewMaterialFromMaskWorldImage(testTileSize, world, loadAsset(t, "rockMask"), LayoutJess, VarientMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.isOpaque(0b1111) {
+		t.Error("full tile of a see through world texture is opaque")
+	}
+}